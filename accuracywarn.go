@@ -0,0 +1,73 @@
+package main
+
+import "time"
+
+// accuracyWarning is true while the status row flashes because the running
+// accuracy dropped below -accuracy-warn.
+var accuracyWarning bool
+
+const accuracyFlash = 400 * time.Millisecond
+
+// accuracyDips tells the goroutine started by startAccuracyWarn to flash.
+var accuracyDips = make(chan struct{}, 1)
+
+// runningAccuracy is the accuracy of the run so far: like Session.Accuracy,
+// but over the characters typed up to now rather than the whole sample.
+func runningAccuracy() float64 {
+	typed := state.TypedIndex + len(state.Mistakes)
+	if typed == 0 {
+		return 100
+	}
+	return 100 * float64(state.TypedIndex) / float64(typed)
+}
+
+// warnAccuracy flashes the status row, unless it's flashing already.
+func warnAccuracy() {
+	select {
+	case accuracyDips <- struct{}{}:
+	default:
+	}
+}
+
+// startAccuracyWarn flashes the status row red for a moment each time
+// warnAccuracy is called, until the returned function is called. Only the
+// status row changes, the typing area is left alone.
+func startAccuracyWarn() func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-accuracyDips:
+			case <-stop:
+				return
+			}
+			drawAccuracyWarning(true)
+
+			select {
+			case <-time.After(accuracyFlash):
+			case <-stop:
+				return
+			}
+			drawAccuracyWarning(false)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		accuracyWarning = false
+		select {
+		case <-accuracyDips:
+		default:
+		}
+	}
+}
+
+func drawAccuracyWarning(lit bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	accuracyWarning = lit
+	render(state.TypedIndex, "status")
+}
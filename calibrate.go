@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// calibration is what -calibrate measured on a terminal, kept in the user
+// config dir so it can be looked at later. Its MinFrameMs paces the updates
+// drawn on their own while typing, see minFrame.
+type calibration struct {
+	Terminal    string    `json:"terminal"`
+	MeasuredAt  time.Time `json:"measured_at"`
+	RoundTripUs int64     `json:"round_trip_us"`
+	FrameUs     int64     `json:"frame_us"`
+
+	// MinFrameMs is the shortest time between updates worth drawing: a frame
+	// faster than the terminal can show it is just more for it to catch up
+	// on.
+	MinFrameMs int `json:"min_frame_ms"`
+}
+
+const calibrationFrames = 200
+
+// minFrame is the MinFrameMs -calibrate recommended for this terminal, or zero
+// if it hasn't been calibrated. Updates that aren't a response to a key (the
+// -race status as the ghost moves) are drawn at most once per minFrame.
+var minFrame time.Duration
+
+// loadMinFrame reads the minimum time between frames saved by -calibrate. A
+// calibration of another terminal, going by $TERM, doesn't apply.
+func loadMinFrame() time.Duration {
+	path, err := calibrationPath()
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var cal calibration
+	if json.Unmarshal(data, &cal) != nil || cal.Terminal != os.Getenv("TERM") {
+		return 0
+	}
+	return time.Duration(cal.MinFrameMs) * time.Millisecond
+}
+
+func calibrationPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "terminal_typing_test", "calibration.json"), nil
+}
+
+// runCalibration times how long the terminal takes to answer a cursor
+// position query, alone and after a batch of frames like the ones drawn
+// while typing, and saves the result.
+func runCalibration() error {
+	saved, err := setupTerminal()
+	if err != nil {
+		return err
+	}
+	defer restoreTerminal(saved)
+	defer fmt.Print("\033[2J\033[H" + showCursor)
+
+	var inputBuf []byte
+	roundTrip, err := timeFlush(&inputBuf, nil)
+	if err != nil {
+		return err
+	}
+
+	batch := new(bytes.Buffer)
+	line := strings.Repeat("calibrating ", terminalWidth/12+1)[:terminalWidth-1]
+	for i := 0; i < calibrationFrames; i++ {
+		row := i%max(terminalHeight-1, 1) + 1
+		fmt.Fprintf(batch, "%s\0337\033[%d;1H\033[%dm%s\033[0m\0338%s", hideCursor, row, 90+i%8, line, showCursor)
+	}
+	total, err := timeFlush(&inputBuf, batch.Bytes())
+	if err != nil {
+		return err
+	}
+
+	frame := max(total-roundTrip, 0) / calibrationFrames
+	cal := calibration{
+		Terminal:    os.Getenv("TERM"),
+		MeasuredAt:  time.Now(),
+		RoundTripUs: roundTrip.Microseconds(),
+		FrameUs:     frame.Microseconds(),
+		MinFrameMs:  int((frame + time.Millisecond - 1) / time.Millisecond),
+	}
+
+	fmt.Print("\033[2J\033[H")
+	fmt.Printf(" Round trip: %v\n\r", roundTrip.Round(time.Microsecond))
+	fmt.Printf(" Per frame:  %v\n\r", frame.Round(time.Microsecond))
+	fmt.Printf(" Recommended minimum time between frames: %dms\n\r", cal.MinFrameMs)
+
+	path, err := calibrationPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf(" Saved to %s\n\r\n\r any key: quit", path)
+	_, err = readRune(&inputBuf)
+	return err
+}
+
+// timeFlush writes frames followed by a cursor position query and returns
+// how long the terminal took to answer it, which it does once everything
+// before has been processed.
+func timeFlush(inputBuf *[]byte, frames []byte) (time.Duration, error) {
+	start := time.Now()
+	os.Stdout.Write(append(frames, "\033[6n"...))
+
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	deadline := start.Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := unix.Poll(fds, int(time.Until(deadline).Milliseconds())+1)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return 0, fmt.Errorf("waiting for the terminal: %w", err)
+		}
+		if n == 0 {
+			continue
+		}
+		r, err := readRune(inputBuf)
+		if err != nil {
+			return 0, err
+		}
+		if r == 'R' {
+			return time.Since(start), nil
+		}
+	}
+	return 0, fmt.Errorf("the terminal didn't answer the cursor position query")
+}
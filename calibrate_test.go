@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The minimum time between frames saved by -calibrate is used on the terminal
+// it was measured on, not on another.
+func TestLoadMinFrame(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TERM", "xterm-256color")
+	if d := loadMinFrame(); d != 0 {
+		t.Errorf("uncalibrated minimum frame time %v, want 0", d)
+	}
+
+	path, err := calibrationPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"terminal": "xterm-256color", "min_frame_ms": 4}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d := loadMinFrame(); d != 4*time.Millisecond {
+		t.Errorf("minimum frame time %v, want 4ms", d)
+	}
+
+	t.Setenv("TERM", "screen")
+	if d := loadMinFrame(); d != 0 {
+		t.Errorf("minimum frame time %v calibrated on another terminal, want 0", d)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// basicTerms are the TERM values, and the prefixes of the families, of
+// terminals that only understand the classic VT escapes. The xterm extensions
+// (the cursor shape, bracketed paste) would show up as literal characters
+// there, in the middle of the sample.
+var basicTerms = []string{"dumb", "vt52", "vt100", "vt102", "vt220", "vt320", "ansi", "cons25", "linux"}
+
+// basicTerminal reports whether the terminal, going by $TERM, is one of the
+// basicTerms. An unset TERM (a serial console, a bare init) counts as one.
+func basicTerminal() bool {
+	name := os.Getenv("TERM")
+	if name == "" {
+		return true
+	}
+	for _, basic := range basicTerms {
+		if name == basic || strings.HasPrefix(name, basic+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// cursorShape reports whether the cursor can be switched to a bar while
+// typing, which -nocursorshape turns off for terminals basicTerms misses.
+func cursorShape() bool {
+	return !*noCursorShp && !basicTerminal()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cardWidth is the number of cells inside the frame of a -card.
+const cardWidth = 34
+
+// cards are the result cards of the runs so far, printed once the terminal
+// is restored so they can be copied as plain text.
+var cards []string
+
+// resultCard frames the results of the run just finished in a box, for
+// sharing.
+func resultCard(index int, elapsed time.Duration, isPB bool) string {
+	name := savedSamples[index].Name
+	if name == "" {
+		name = savedSamples[index].Text
+	}
+	speed := state.WPM()
+	if *speedUnit == "cpm" {
+		speed = state.CPM()
+	}
+	headline := fmt.Sprintf("%.1f %s", speed, *speedUnit)
+	if isPB {
+		headline = fmt.Sprintf("%-18s★ new PB", headline)
+	}
+
+	lines := []string{
+		"Terminal Typing Test",
+		"",
+		headline,
+		fmt.Sprintf("Accuracy  %.1f%%", state.Accuracy()),
+		"Time      " + formatElapsed(elapsed),
+		"Sample    " + menuPreview(name, cardWidth-14),
+		"Date      " + time.Now().Format(time.DateOnly),
+	}
+	var card strings.Builder
+	card.WriteString("╭" + strings.Repeat("─", cardWidth) + "╮\n")
+	for _, line := range lines {
+		cells := 0
+		for _, r := range line {
+			cells += runeWidth(r)
+		}
+		fmt.Fprintf(&card, "│  %s%s│\n", line, strings.Repeat(" ", max(cardWidth-2-cells, 0)))
+	}
+	card.WriteString("╰" + strings.Repeat("─", cardWidth) + "╯\n")
+	return card.String()
+}
+
+// printCards prints the cards of the session's runs, in order.
+func printCards() {
+	for _, card := range cards {
+		fmt.Print(card)
+	}
+	cards = nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "ttt/typing"
+
+// controlAction is what a control byte (below 32) read while typing does.
+type controlAction int
+
+const (
+	// controlIgnore drops the byte. It's the action of every control byte
+	// not in controlBytes, so odd modifier combos don't count as typos.
+	controlIgnore controlAction = iota
+	// controlFeed passes the byte on to the typing engine as is.
+	controlFeed
+	// controlEnter handles the byte as Enter.
+	controlEnter
+	controlQuit
+	controlSkip
+	// controlPause pauses the run, or resumes it. It's handled by the run
+	// loop, see togglePause.
+	controlPause
+)
+
+// controlBytes lists the control bytes that mean something while typing.
+var controlBytes = map[rune]controlAction{
+	3:                            controlQuit,  // Ctrl-C
+	14:                           controlSkip,  // Ctrl-N
+	16:                           controlPause, // Ctrl-P
+	'\t':                         controlFeed,  // Tab, for samples with tabs
+	'\r':                         controlFeed,  // Enter
+	'\n':                         controlEnter, // Ctrl-Enter and Ctrl-J on some terminals
+	typing.KeyCtrlBackspace:      controlFeed,  // Ctrl-Backspace (Ctrl-W)
+	typing.KeyCtrlShiftBackspace: controlFeed,  // Ctrl-Shift-Backspace (Ctrl-H)
+	typing.KeyEsc:                controlFeed,  // Esc, cancels the word being typed
+}
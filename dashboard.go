@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"ttt/typing"
+)
+
+// sparkRuns is how many of the latest logged runs the dashboard sparkline
+// shows, which keeps it within an 80 column terminal.
+const sparkRuns = 50
+
+// readResultLog returns the runs in the -log file at path, oldest first, with
+// the notes added to them. A missing file has no runs, and lines that don't
+// parse (a run cut short while being written) are skipped.
+func readResultLog(path string) ([]logEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening results log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []logEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var note logNote
+		if json.Unmarshal(scanner.Bytes(), &note) == nil && !note.For.IsZero() {
+			for i := len(entries) - 1; i >= 0; i-- {
+				if entries[i].Time.Equal(note.For) {
+					entries[i].Note = note.Note
+					break
+				}
+			}
+			continue
+		}
+		var entry logEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading results log: %w", err)
+	}
+	return entries, nil
+}
+
+// pbWPM is the speed of the sample's personal best, or zero if it has none.
+func pbWPM(sample *SavedSample) float64 {
+	if sample.PersonalBest <= 0 {
+		return 0
+	}
+	text := []rune(norm.NFC.String(sample.Text))
+	words := float64(typing.CountWords(text))
+	if n := wordLen(); n > 0 {
+		// A personal best is a clean run, every character typed right.
+		s := typing.NewSession(sample.Text, nil)
+		s.Graphemes = *graphemes
+		words = float64(s.CharCount(len(s.Sample))) / float64(n)
+	}
+	return words / time.Duration(sample.PersonalBest).Minutes()
+}
+
+// sparkline draws series as a single row of block characters, scaled to its
+// highest value.
+func sparkline(series []float64) string {
+	top := 0.0
+	for _, v := range series {
+		top = max(top, v)
+	}
+	var line strings.Builder
+	for _, v := range series {
+		level := 0
+		if top > 0 {
+			level = int(v / top * float64(len(graphBlocks)-2))
+		}
+		line.WriteRune(graphBlocks[level+1])
+	}
+	return line.String()
+}
+
+// showDashboard prints an overview of every sample's personal best and, with
+// -log, of the runs logged so far.
+func showDashboard() error {
+	var entries []logEntry
+	if *resultLog != "" {
+		var err error
+		if entries, err = readResultLog(*resultLog); err != nil {
+			return err
+		}
+	}
+
+	withPB, pbSum := 0, 0.0
+	best, bestSample := 0.0, -1
+	for i := range savedSamples {
+		if wpm := pbWPM(&savedSamples[i]); wpm > 0 {
+			withPB++
+			pbSum += wpm
+			if wpm > best {
+				best, bestSample = wpm, i
+			}
+		}
+	}
+	logSum := 0.0
+	for _, entry := range entries {
+		logSum += entry.WPM
+		if entry.WPM > best {
+			best, bestSample = entry.WPM, entry.Sample
+		}
+	}
+
+	row := func(label, format string, args ...any) {
+		fmt.Printf(" %-13s "+format+"\n", append([]any{label}, args...)...)
+	}
+	fmt.Printf("\033[97m Terminal Typing Test dashboard\033[0m\n\n")
+	row("Samples", "%d, %d with a personal best", len(savedSamples), withPB)
+	if *resultLog == "" {
+		row("Attempts", "\033[90mnot tracked, use -log\033[0m")
+	} else {
+		row("Attempts", "%d", len(entries))
+	}
+
+	switch {
+	case len(entries) > 0:
+		row("Average wpm", "%.1f over the logged runs", logSum/float64(len(entries)))
+	case withPB > 0:
+		row("Average wpm", "%.1f over the personal bests", pbSum/float64(withPB))
+	default:
+		row("Average wpm", "n/a")
+	}
+
+	if bestSample < 0 {
+		row("Best wpm", "n/a, no run finished yet")
+	} else {
+		label := fmt.Sprintf("sample %d", bestSample)
+		if bestSample < len(savedSamples) {
+			name := savedSamples[bestSample].Name
+			if name == "" {
+				name = menuPreview(savedSamples[bestSample].Text, 40)
+			}
+			label += fmt.Sprintf(" (%s)", name)
+		}
+		row("Best wpm", "%.1f, %s", best, label)
+	}
+
+	if len(entries) > 0 {
+		recent := entries[max(len(entries)-sparkRuns, 0):]
+		series := make([]float64, len(recent))
+		for i, entry := range recent {
+			series[i] = entry.WPM
+		}
+		row("Recent runs", "\033[96m%s\033[0m last %d", sparkline(series), len(recent))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+// drillWord is the word with a typo that -drill is waiting to insert again,
+// and drillEnd the position right after it in the sample.
+var (
+	drillWord []rune
+	drillEnd  int
+)
+
+// noteDrillMistake remembers the word the typo at idx was made in, to be
+// typed again once it's finished. A typo on the space after a word counts
+// for that word.
+func noteDrillMistake(idx int) {
+	start, end := idx, idx
+	if isSpace(state.Sample[idx]) {
+		start--
+	} else {
+		for end < len(state.Sample) && !isSpace(state.Sample[end]) {
+			end++
+		}
+	}
+	for start >= 0 && !isSpace(state.Sample[start]) {
+		start--
+	}
+	if start+1 >= end {
+		return
+	}
+	drillWord = append([]rune(nil), state.Sample[start+1:end]...)
+	drillEnd = end
+}
+
+// drillInsert adds the pending drill word again right where typing is, once
+// it and the space after it have been typed without typos left, and redraws
+// the sample. The word isn't added if the sample would no longer fit on the
+// screen.
+func drillInsert() {
+	at := state.TypedIndex
+	if drillWord == nil || at <= drillEnd && at < len(state.Sample) || len(state.Typos) > 0 {
+		return
+	}
+
+	var text []rune
+	if at == len(state.Sample) {
+		text = append([]rune{' '}, drillWord...)
+	} else {
+		text = append(append(text, drillWord...), state.Sample[drillEnd])
+	}
+	drillWord = nil
+
+	sample := append(append(append([]rune(nil), state.Sample[:at]...), text...), state.Sample[at:]...)
+	if topRows()+rowsNeeded(sample)+reservedRows() > terminalHeight {
+		return
+	}
+	state.Insert(at, text)
+	setupGutter(state.Sample)
+	if *remaining || *wordPos {
+		wordsLeft = suffixWordCounts(state.Sample)
+	}
+
+	trail = nil
+	typeRow, typeCol, teachIndex = 0, 0, -1
+	render(0, "initial")
+	renderProgress(0)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// editSample opens the text of sample in $VISUAL or $EDITOR (vi if neither
+// is set) and saves it back. Timings recorded for the old text are cleared.
+// The editor gets a temporary copy, even with -dir, so the sample is only
+// changed once the editor exits cleanly with some text in it.
+func editSample(sample *SavedSample) error {
+	tmp, err := os.CreateTemp("", "ttt-sample-*.txt")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	path := tmp.Name()
+	defer os.Remove(path)
+	_, err = tmp.WriteString(sample.Text + "\n")
+	tmp.Close()
+	if err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s, sample left unchanged: %w", args[0], err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading edited sample: %w", err)
+	}
+	text := strings.TrimRight(string(content), "\r\n")
+	if text == "" {
+		return fmt.Errorf("the edited sample is empty, left unchanged")
+	}
+	if text == sample.Text {
+		fmt.Println("Sample unchanged")
+		return nil
+	}
+
+	if *sampleDir != "" {
+		if err := os.WriteFile(filepath.Join(*sampleDir, sample.Name), []byte(text+"\n"), 0644); err != nil {
+			return fmt.Errorf("saving the edited sample: %w", err)
+		}
+	}
+	sample.Text = text
+	sample.CharTimes = nil
+	sample.PersonalBest = 0
+	sample.Repeats = nil
+	persistSamples()
+	fmt.Println("Sample updated, its personal best was cleared")
+	return nil
+}
+
+// resetSamples clears the personal best and char times of the sample picked
+// by sel, or of every sample if sel is "all" (after asking).
+func resetSamples(sel string) error {
+	var targets []*SavedSample
+	if sel == "all" {
+		fmt.Printf("Reset the personal bests of all %d samples? [y/N] ", len(savedSamples))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "Y" {
+			fmt.Println("Nothing reset")
+			return nil
+		}
+		for i := range savedSamples {
+			targets = append(targets, &savedSamples[i])
+		}
+	} else {
+		sample, err := selectSample(sel)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, sample)
+	}
+
+	for _, sample := range targets {
+		forgetPersonalBest(sampleIndex(sample))
+		sample.CharTimes = nil
+		sample.PersonalBest = 0
+		sample.Repeats = nil
+	}
+	persistSamples()
+	fmt.Printf("Reset %d sample(s)\n", len(targets))
+	return nil
+}
+
+// setDefaultSample marks the sample picked by sel as the default one, in
+// place of any other.
+func setDefaultSample(sel string) error {
+	sample, err := selectSample(sel)
+	if err != nil {
+		return err
+	}
+	for i := range savedSamples {
+		savedSamples[i].Default = false
+	}
+	sample.Default = true
+	persistSamples()
+	fmt.Printf("Sample %d is now the default\n", sampleIndex(sample))
+	return nil
+}
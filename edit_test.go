@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// With -dir the sample's .txt file only changes once the editor exits
+// cleanly with some text in it: a failed or emptied edit leaves it as it was.
+func TestEditSampleDir(t *testing.T) {
+	saveGlobals(t)
+	savedDir := *sampleDir
+	t.Cleanup(func() { *sampleDir = savedDir })
+	*sampleDir = t.TempDir()
+	path := filepath.Join(*sampleDir, "hello.txt")
+	if err := os.WriteFile(path, []byte("hello there\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadSampleDir(*sampleDir); err != nil {
+		t.Fatal(err)
+	}
+	edited := filepath.Join(t.TempDir(), "edited.txt")
+	if err := os.WriteFile(edited, []byte("hello again\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		editor, want string
+		fails        bool
+	}{
+		{"false", "hello there\n", true},
+		{"truncate -s 0", "hello there\n", true},
+		{"cp " + edited, "hello again\n", false},
+	} {
+		t.Setenv("VISUAL", test.editor)
+		err := editSample(&savedSamples[0])
+		if (err != nil) != test.fails {
+			t.Errorf("editing with %q returned %v", test.editor, err)
+		}
+		if content, _ := os.ReadFile(path); string(content) != test.want {
+			t.Errorf("after editing with %q the file holds %q, want %q", test.editor, content, test.want)
+		}
+	}
+	if savedSamples[0].Text != "hello again" {
+		t.Errorf("sample text %q after the edit, want %q", savedSamples[0].Text, "hello again")
+	}
+}
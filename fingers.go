@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type fingerKeys struct {
+	finger string
+	keys   string
+}
+
+// QWERTY touch-typing assignment, both shifted and unshifted keys.
+var fingerMap = []fingerKeys{
+	{"left pinky", "`1qaz~!QAZ\t"},
+	{"left ring", "2wsx@WSX"},
+	{"left middle", "3edc#EDC"},
+	{"left index", "4rfv5tgb$RFV%TGB"},
+	{"right index", "6yhn7ujm^YHN&UJM"},
+	{"right middle", "8ik,*IK<"},
+	{"right ring", "9ol.(OL>"},
+	{"right pinky", "0p;/-['=]\\)P:?_{\"+}|\n"},
+	{"thumbs", " "},
+}
+
+type fingerCount struct {
+	finger string
+	typos  int
+}
+
+func fingerFor(r rune) string {
+	for _, fk := range fingerMap {
+		if strings.ContainsRune(fk.keys, r) {
+			return fk.finger
+		}
+	}
+	return "other"
+}
+
+// fingerReport groups every typo made during the run (corrected or not) by the
+// finger responsible for the expected character, most error-prone first.
+func fingerReport(sample []rune, mistakes []int) []fingerCount {
+	counts := make(map[string]int)
+	for _, idx := range mistakes {
+		counts[fingerFor(sample[idx])]++
+	}
+
+	report := make([]fingerCount, 0, len(counts))
+	for finger, typos := range counts {
+		report = append(report, fingerCount{finger, typos})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].typos != report[j].typos {
+			return report[i].typos > report[j].typos
+		}
+		return report[i].finger < report[j].finger
+	})
+	return report
+}
+
+// keyboardRows are the unshifted keys of a US QWERTY keyboard, row by row,
+// with how far (in keys) each row is shifted right of the number row.
+var keyboardRows = []struct {
+	keys   string
+	offset float64
+}{
+	{"`1234567890-=", 0},
+	{"qwertyuiop[]\\", 1.5},
+	{"asdfghjkl;'", 1.75},
+	{"zxcvbnm,./", 2.25},
+}
+
+// baseKey is the unshifted key that produces r.
+func baseKey(r rune) rune {
+	if i := strings.IndexRune(shiftedKeys, r); i >= 0 {
+		return rune(unshiftedKeys[i])
+	}
+	return unicode.ToLower(r)
+}
+
+func keyPosition(r rune) (row int, x float64, ok bool) {
+	for row, kr := range keyboardRows {
+		if i := strings.IndexRune(kr.keys, r); i >= 0 {
+			return row, kr.offset + float64(i), true
+		}
+	}
+	return 0, 0, false
+}
+
+// nearMiss reports whether typing typed instead of expected was a slip onto
+// a neighbouring key (or the same key with the wrong shift) rather than a
+// different key altogether.
+func nearMiss(expected, typed rune) bool {
+	a, b := baseKey(expected), baseKey(typed)
+	if a == b {
+		return true
+	}
+	rowA, xA, okA := keyPosition(a)
+	rowB, xB, okB := keyPosition(b)
+	if !okA || !okB {
+		return false
+	}
+	dx := math.Abs(xA - xB)
+	switch rowA - rowB {
+	case 0:
+		return dx == 1
+	case 1, -1:
+		return dx <= 0.75
+	}
+	return false
+}
+
+// nearMissCounts splits every typo made during the run into near misses and
+// far ones, from what was typed instead of each expected character.
+func nearMissCounts(sample []rune, mistakes []int, typed []rune) (near, far int) {
+	for i, idx := range mistakes {
+		if i < len(typed) && nearMiss(sample[idx], typed[i]) {
+			near++
+		} else {
+			far++
+		}
+	}
+	return near, far
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// generator makes the text of a sample on the fly from the argument given
+// after its name in -generator name:arg (empty if there's none).
+type generator func(arg string) (string, error)
+
+var generators = map[string]generator{}
+
+// registerGenerator makes gen selectable as -generator name.
+func registerGenerator(name string, gen generator) {
+	if _, ok := generators[name]; ok {
+		panic("generator registered twice: " + name)
+	}
+	generators[name] = gen
+}
+
+func init() {
+	registerGenerator("words", generateWords)
+	registerGenerator("numbers", generateNumbers)
+	registerGenerator("quotes", generateQuote)
+	registerGenerator("lines", generateLines)
+}
+
+// loadGenerated replaces the saved samples with a single one made by the
+// generator selected with spec (name or name:arg). Generated samples change
+// every time, so nothing about them is saved.
+func loadGenerated(spec string) error {
+	name, arg, _ := strings.Cut(spec, ":")
+	gen, ok := generators[name]
+	if !ok {
+		names := make([]string, 0, len(generators))
+		for n := range generators {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown generator %q (have %s)", name, strings.Join(names, ", "))
+	}
+
+	text, err := gen(arg)
+	if err != nil {
+		return fmt.Errorf("generator %s: %w", name, err)
+	}
+	if text == "" {
+		return fmt.Errorf("generator %s: made an empty sample", name)
+	}
+	savedSamples = []SavedSample{{Name: name, Text: text}}
+	return nil
+}
+
+// saveGenerated adds the sample just generated to the saved samples, named
+// after its generator with a "generated" tag, and selects it. From then on
+// it's an ordinary sample, with a personal best and a ghost, that -sample
+// can pick by that name.
+func saveGenerated() error {
+	generated := savedSamples[0]
+	if err := loadSamples(); err != nil {
+		return err
+	}
+
+	prefix := "generated " + generated.Name + " "
+	n := 1
+	for _, sample := range savedSamples {
+		if i, err := strconv.Atoi(strings.TrimPrefix(sample.Name, prefix)); err == nil && strings.HasPrefix(sample.Name, prefix) {
+			n = max(n, i+1)
+		}
+	}
+	generated.Name = prefix + strconv.Itoa(n)
+	savedSamples = append(savedSamples, generated)
+
+	*sampleGen = ""
+	*sampleSel = generated.Name
+	persistSamples()
+	return nil
+}
+
+// countArg parses the optional count argument of a generator.
+func countArg(arg string, def int) (int, error) {
+	if arg == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("count must be a positive number, got %q", arg)
+	}
+	return n, nil
+}
+
+var commonWords = strings.Fields(`
+	the be to of and a in that have it for not on with he as you do at this
+	but his by from they we say her she or an will my one all would there
+	their what so up out if about who get which go me when make can like
+	time no just him know take people into year your good some could them
+	see other than then now look only come its over think also back after
+	use two how our work first well way even new want because any these
+	give day most us is was are were been has had did said made found long
+	little very through where much before right too mean old same tell
+	boy follow came show around form three small set put end does
+	another large must big high such turn here why ask went men read need
+	land different home move try kind hand picture again change off play
+	spell air away animal house point page letter mother answer study
+	still learn should world`)
+
+// wordList is where the words generator picks from: commonWords, unless
+// -wordlist loaded another list.
+var wordList = commonWords
+
+// monkeyTypeList is the JSON format of MonkeyType's word lists, e.g.
+// {"name": "english", "words": ["the", "be", ...]}. Their other fields
+// (orderedByFrequency, bcp47...) aren't needed here.
+type monkeyTypeList struct {
+	Name  string   `json:"name"`
+	Words []string `json:"words"`
+}
+
+// loadWordList makes the words generator pick from the MonkeyType list in the
+// file at path. Words repeated in the list are only kept once, so they're no
+// more likely to come up than the rest.
+func loadWordList(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading word list: %w", err)
+	}
+	var list monkeyTypeList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return fmt.Errorf("parsing word list %s: %w", path, err)
+	}
+	if len(list.Words) == 0 {
+		return fmt.Errorf("word list %s has no \"words\"", path)
+	}
+
+	seen := make(map[string]bool)
+	words := make([]string, 0, len(list.Words))
+	for i, word := range list.Words {
+		word = strings.TrimSpace(word)
+		if word == "" || strings.ContainsFunc(word, unicode.IsSpace) {
+			return fmt.Errorf("word list %s: word %d (%q) must be a single word", path, i, list.Words[i])
+		}
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	wordList = words
+	return nil
+}
+
+// The chances of a word being followed by a period or a comma with -punct,
+// which makes sentences about a dozen words long.
+const (
+	periodChance = 0.08
+	commaChance  = 0.10
+)
+
+// generateWords picks arg (default 30) random words from wordList. With
+// -punct they're split into sentences, with commas here and there, and with
+// -caps each sentence starts with a capital. The same -seed makes the same
+// text.
+func generateWords(arg string) (string, error) {
+	n, err := countArg(arg, 30)
+	if err != nil {
+		return "", err
+	}
+	rng := seededRand()
+	words := make([]string, n)
+	sentenceStart := true
+	for i := range words {
+		word := wordList[rng.IntN(len(wordList))]
+		if *caps && sentenceStart {
+			word = capitalize(word)
+		}
+		sentenceStart = false
+		if *punct && i < n-1 {
+			switch p := rng.Float64(); {
+			case p < periodChance:
+				word += "."
+				sentenceStart = true
+			case p < periodChance+commaChance:
+				word += ","
+			}
+		}
+		words[i] = word
+	}
+	if *punct {
+		words[n-1] += "."
+	}
+	return strings.Join(words, " "), nil
+}
+
+// seededRand is a random source seeded with -seed, or with the time if it's
+// not set.
+func seededRand() *rand.Rand {
+	seed := uint64(*shuffleSeed)
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+func capitalize(word string) string {
+	runes := []rune(word)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// generateNumbers makes arg (default 20) random numbers of one to five
+// digits.
+func generateNumbers(arg string) (string, error) {
+	n, err := countArg(arg, 20)
+	if err != nil {
+		return "", err
+	}
+	numbers := make([]string, n)
+	for i := range numbers {
+		digits := 1 + rand.IntN(5)
+		numbers[i] = strconv.Itoa(rand.IntN(pow10(digits)))
+	}
+	return strings.Join(numbers, " "), nil
+}
+
+func pow10(n int) int {
+	p := 1
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// generateQuote picks a random quote from the file arg, where quotes are
+// separated by blank lines.
+func generateQuote(arg string) (string, error) {
+	if arg == "" {
+		return "", fmt.Errorf("needs the quotes file, as quotes:path")
+	}
+	content, err := os.ReadFile(arg)
+	if err != nil {
+		return "", err
+	}
+
+	var quotes []string
+	for _, quote := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n\n") {
+		if quote = strings.TrimSpace(quote); quote != "" {
+			quotes = append(quotes, quote)
+		}
+	}
+	if len(quotes) == 0 {
+		return "", fmt.Errorf("no quotes in %s", arg)
+	}
+	return quotes[rand.IntN(len(quotes))], nil
+}
+
+// generateLines takes the lines from-to (counting from 1, both included) of
+// the file arg, given as path:from-to, or the whole file without a range.
+// Indentation is kept as it is, tabs included, so a piece of code can be
+// practiced as written. Like every generated sample the range has no ghost
+// or personal best.
+func generateLines(arg string) (string, error) {
+	path, from, to := arg, 1, 0
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		if f, t, ok := strings.Cut(arg[i+1:], "-"); ok {
+			var errFrom, errTo error
+			from, errFrom = strconv.Atoi(f)
+			to, errTo = strconv.Atoi(t)
+			if errFrom != nil || errTo != nil || from < 1 || to < from {
+				return "", fmt.Errorf("line range must be from-to, like 40-60, got %q", arg[i+1:])
+			}
+			path = arg[:i]
+		}
+	}
+	if path == "" {
+		return "", fmt.Errorf("needs the file, as lines:path or lines:path:from-to")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), "\n")
+	if to == 0 || to > len(lines) {
+		to = len(lines)
+	}
+	if from > to {
+		return "", fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+	selected := lines[from-1 : to]
+	for i, line := range selected {
+		selected[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(selected, "\n"), "\n"), nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+
+	"golang.org/x/exp/slices"
+)
+
+// ghostCaretColor marks the character the ghost types next with -ghostcaret.
+const ghostCaretColor = "\033[4;95m"
+
+// ghostCaretShown reports whether the -ghostcaret marker is shown at index,
+// which -ghostlate holds back until the second half of the sample.
+func ghostCaretShown(index int) bool {
+	return index < len(state.Sample) && (!*ghostLate || index > len(state.Sample)/2)
+}
+
+// moveGhostCaret moves the -ghostcaret marker from the character before
+// index, repainted as it stands, to the one at index, the next the ghost
+// types. Nothing else is recolored.
+func moveGhostCaret(frame *bytes.Buffer, index int) {
+	if index > 0 {
+		redrawSampleChar(frame, index-1)
+	}
+	if ghostCaretShown(index) && isHidden(index) {
+		drawGlyph(frame, index, ghostCaretColor, ' ')
+	} else if ghostCaretShown(index) {
+		drawSampleChar(frame, index, ghostCaretColor)
+	}
+}
+
+// redrawSampleChar repaints the sample character at index i as typing left
+// it: gray (or blank, see -recall) if it's still to type, a red typo or
+// typedColor.
+func redrawSampleChar(frame *bytes.Buffer, i int) {
+	switch {
+	case isHidden(i):
+		drawGlyph(frame, i, "\033[4;90m", ' ')
+	case i >= state.TypedIndex:
+		drawSampleChar(frame, i, "\033[90m")
+	case slices.Contains(state.Typos, i):
+		drawGlyph(frame, i, "\033[4;91m", typoGlyph(state.Sample[i]))
+	default:
+		drawSampleChar(frame, i, typedColor())
+	}
+}
@@ -4,7 +4,7 @@ go 1.24.0
 
 require (
 	golang.org/x/exp v0.0.0-20250218142911-aa4b98e5adaa
+	golang.org/x/sys v0.30.0
 	golang.org/x/term v0.29.0
+	golang.org/x/text v0.22.0
 )
-
-require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wpmSeries splits the run into n windows of consecutive characters and
+// returns the speed in each, in words (of -worddiv characters) per minute,
+// smoothed with its neighbours so single slow keys don't dominate.
+func wpmSeries(charTimes []int, n int) []float64 {
+	n = min(n, len(charTimes))
+	if n <= 0 {
+		return nil
+	}
+	raw := make([]float64, n)
+	for w := range raw {
+		from, to := w*len(charTimes)/n, (w+1)*len(charTimes)/n
+		ms := 0
+		for _, t := range charTimes[from:to] {
+			ms += t
+		}
+		if ms > 0 {
+			raw[w] = charsWPM(to-from, ms)
+		} else if w > 0 {
+			raw[w] = raw[w-1]
+		}
+	}
+
+	smooth := make([]float64, n)
+	for w := range raw {
+		sum, count := 0.0, 0
+		for k := max(w-1, 0); k <= min(w+1, n-1); k++ {
+			sum += raw[k]
+			count++
+		}
+		smooth[w] = sum / float64(count)
+	}
+	return smooth
+}
+
+var graphBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// graphLines draws series as a column chart of the given height, with the
+// wpm scale on the left.
+func graphLines(series []float64, height int) []string {
+	top := 0.0
+	for _, v := range series {
+		top = max(top, v)
+	}
+	if top == 0 {
+		top = 1
+	}
+
+	lines := make([]string, height)
+	for row := range height {
+		label := "      "
+		switch row {
+		case 0:
+			label = fmt.Sprintf("%5.0f ", top)
+		case height - 1:
+			label = fmt.Sprintf("%5d ", 0)
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "\033[90m%s│\033[96m", label)
+		floor := (height - 1 - row) * 8
+		for _, v := range series {
+			eighths := int(v / top * float64(height*8))
+			b.WriteRune(graphBlocks[min(max(eighths-floor, 0), 8)])
+		}
+		b.WriteString("\033[0m")
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// showGraph draws how the speed changed over the course of the run, from
+// the char times, until a key is pressed.
+func showGraph(inputBuf *[]byte) error {
+	height := max(terminalHeight-3, 2)
+	series := wpmSeries(state.CharTimes, terminalWidth-8)
+
+	fmt.Print("\033[2J") //clean screen
+	fmt.Printf("\033[H") //return home
+	fmt.Printf(" \033[97mwpm over the run\033[0m\n\r")
+	fmt.Print(strings.Join(graphLines(series, height), "\n\r"))
+	fmt.Printf("\n\r\033[90m       start%*s\033[0m", max(len(series)-5, 0), "end")
+	fmt.Printf("\033[%d;1H\033[90m any key: back\033[0m", terminalHeight)
+
+	r, err := readKey(inputBuf)
+	if err != nil {
+		return err
+	}
+	if r == 3 {
+		handleCtrlC()
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// inlineTop is the number of rows above the sample line with -inline: the
+// line goes where the cursor was, under whatever the shell printed before.
+var inlineTop int
+
+// placeInline makes room for the sample line and the status row below the
+// cursor, scrolling if it's near the bottom, and centers the line there. The
+// sample has to fit on one line, -inline has no room to wrap it.
+func placeInline() error {
+	cells := cellsBefore(state.Sample, len(state.Sample))
+	if multiLine || cells >= terminalWidth {
+		return fmt.Errorf("-inline needs a sample that fits on one line of the terminal (%d columns)", terminalWidth)
+	}
+	centerInline()
+
+	// In raw mode a newline only moves down a row, scrolling at the bottom.
+	rows := 1 + reservedRows()
+	fmt.Printf("\r%s\033[%dA", strings.Repeat("\n", rows), rows)
+	row, err := cursorRow()
+	if err != nil {
+		return err
+	}
+	inlineTop = row - 1
+	return nil
+}
+
+// centerInline centers the sample line, using the gutter as the margin.
+func centerInline() {
+	gutterWidth = max(terminalWidth-cellsBefore(state.Sample, len(state.Sample)), 0) / 2
+}
+
+// cursorRow asks the terminal which row the cursor is on, counting from 1.
+func cursorRow() (int, error) {
+	fmt.Print("\033[6n")
+	tty.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	defer tty.SetReadDeadline(time.Time{})
+
+	var response []byte
+	buf := make([]byte, 32)
+	for !bytes.HasSuffix(response, []byte("R")) {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return 0, fmt.Errorf("the terminal didn't report the cursor position: %w", err)
+		}
+		response = append(response, buf[:n]...)
+	}
+
+	var row, col int
+	report := response[max(bytes.LastIndex(response, []byte("\033[")), 0):]
+	if _, err := fmt.Sscanf(string(report), "\033[%d;%dR", &row, &col); err != nil || row < 1 {
+		return 0, fmt.Errorf("unexpected cursor position report %q", report)
+	}
+	return row, nil
+}
+
+// clearScreen is the escape that clears the screen for the results, or to
+// leave, with the cursor at the top. With -inline only the rows below the
+// sample line are cleared, the rest of the terminal is left as it was.
+func clearScreen() string {
+	if *inline {
+		return fmt.Sprintf("\033[%d;1H\033[J", topRows()+2)
+	}
+	return "\033[2J\033[H"
+}
+
+// clearTypingArea clears the screen before the sample is drawn, or with
+// -inline only from the sample line down.
+func clearTypingArea(frame *bytes.Buffer) {
+	if *inline {
+		fmt.Fprintf(frame, "\033[%d;1H\033[J", topRows()+1)
+		return
+	}
+	fmt.Fprint(frame, "\033[H\033[2J") //clean and home
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"ttt/typing"
+)
+
+// With bracketed paste enabled the terminal wraps pasted text in these
+// markers, which lets pastes be told apart from typing and dropped.
+const (
+	pasteStart = "\033[200~"
+	pasteEnd   = "\033[201~"
+)
+
+var bracketedPaste bool
+
+// keys is where keystrokes are read from, and tty the terminal put in raw
+// mode and asked for its size. Both are stdin unless -input is given.
+var (
+	keys = os.Stdin
+	tty  = os.Stdin
+)
+
+// openInput reads the keystrokes from the file at path (a recorded run, or a
+// named pipe fed by a script) instead of stdin. Stdin may not be a terminal
+// then, so the controlling terminal is opened as /dev/tty for raw mode and
+// the size, and the output still goes to stdout, e.g.
+//
+//	ttt -input keys.txt < /dev/null
+func openInput(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening input: %w", err)
+	}
+	terminal, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening the controlling terminal: %w", err)
+	}
+	keys, tty = file, terminal
+	return nil
+}
+
+func enableBracketedPaste() {
+	if basicTerminal() {
+		return
+	}
+	os.Stdout.WriteString("\033[?2004h")
+	bracketedPaste = true
+}
+
+func disableBracketedPaste() {
+	if bracketedPaste {
+		os.Stdout.WriteString("\033[?2004l")
+		bracketedPaste = false
+	}
+}
+
+// Holding a key down makes the terminal repeat it every few tens of
+// milliseconds, at a steady rate. With -repeatms, a keystroke identical to
+// the two before it, all less than -repeatms apart and at the same interval,
+// is taken for such a repeat and dropped, so a held or stuck key neither
+// races through the sample nor skews the timing. Backspace and
+// Ctrl-Backspace are exempt, holding them is meant to repeat.
+//
+// The times are those the keystrokes were read at, not pressed at: two keys
+// sent in one packet (over SSH) or left buffered while the program was busy
+// are read microseconds apart. A single pair is never judged, and gaps under
+// minRepeatGap are never taken for repeats, so "oo" typed fast is kept.
+var (
+	lastKey     rune
+	lastKeyAt   time.Time
+	lastGap     time.Duration
+	autoRepeats int
+)
+
+// minRepeatGap is shorter than any terminal's auto-repeat interval, a gap
+// under it comes from reading keystrokes that were waiting together.
+const minRepeatGap = 5 * time.Millisecond
+
+// autoRepeated reports whether r, read at the given time, is an auto-repeat
+// to drop. Keystrokes read from a file with -input all come at once, so
+// they're never taken for repeats.
+func autoRepeated(r rune, at time.Time) bool {
+	gap := at.Sub(lastKeyAt)
+	quick := r == lastKey && gap >= minRepeatGap && gap < time.Duration(*repeatMs)*time.Millisecond
+	steady := quick && lastGap > 0 && (gap-lastGap).Abs() <= lastGap/4
+	repeated := steady && r != typing.KeyBackspace && r != typing.KeyCtrlBackspace && keys == tty
+	if quick {
+		lastGap = gap
+	} else {
+		lastGap = 0
+	}
+	lastKey, lastKeyAt = r, at
+	if repeated {
+		autoRepeats++
+	}
+	return repeated
+}
+
+// inputPending reports whether more input arrives within a few milliseconds,
+// which tells an escape sequence apart from a lone Esc keypress.
+func inputPending(inputBuf []byte) bool {
+	if len(inputBuf) > 0 {
+		return true
+	}
+	fds := []unix.PollFd{{Fd: int32(keys.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, 10)
+	return err == nil && n > 0
+}
+
+// readInput reads the next keystroke like readComposed, dropping escape
+// sequences (arrow keys, Alt combos) and anything pasted.
+func readInput(inputBuf *[]byte, expected rune) ([]rune, error) {
+	for {
+		runes, err := readComposed(inputBuf, expected)
+		if err != nil || len(runes) != 1 || runes[0] != 27 || !inputPending(*inputBuf) {
+			return runes, err
+		}
+		if err := skipEscapeSequence(inputBuf); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// skipEscapeSequence consumes the rest of a sequence whose ESC was just read.
+// A bracketed paste is consumed up to its end marker.
+func skipEscapeSequence(inputBuf *[]byte) error {
+	r, err := readRune(inputBuf)
+	if err != nil || r != '[' {
+		return err
+	}
+
+	seq := []rune("\033[")
+	for {
+		if r, err = readRune(inputBuf); err != nil {
+			return err
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+	if string(seq) != pasteStart {
+		return nil
+	}
+
+	tail := []rune{}
+	for string(tail) != pasteEnd {
+		if r, err = readRune(inputBuf); err != nil {
+			return err
+		}
+		tail = append(tail, r)
+		if len(tail) > len(pasteEnd) {
+			tail = tail[1:]
+		}
+	}
+	return nil
+}
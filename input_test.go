@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// pipeKeys makes the keystrokes read from a pipe for the rest of the test,
+// and returns its write end.
+func pipeKeys(t *testing.T) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedKeys := keys
+	keys = r
+	t.Cleanup(func() {
+		keys = savedKeys
+		r.Close()
+		w.Close()
+	})
+	return w
+}
+
+func TestReadComposed(t *testing.T) {
+	tests := []struct {
+		name  string
+		typed string
+		want  []rune
+	}{
+		{"composed", "é", []rune{'é'}},
+		{"decomposed", "é", []rune{'é'}},
+		{"plain e", "e", []rune{'e'}},
+		{"e then another key", "ex", []rune{'e', 'x'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := pipeKeys(t)
+			w.WriteString(tt.typed)
+			var inputBuf []byte
+			got, err := readComposed(&inputBuf, 'é')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readComposed(%q) = %q, want %q", tt.typed, string(got), string(tt.want))
+			}
+		})
+	}
+}
+
+// A plain e typed for é mustn't wait for the next key to be seen.
+func TestReadComposedDoesNotWait(t *testing.T) {
+	w := pipeKeys(t)
+	w.WriteString("e")
+	done := make(chan []rune)
+	go func() {
+		var inputBuf []byte
+		runes, _ := readComposed(&inputBuf, 'é')
+		done <- runes
+	}()
+	select {
+	case got := <-done:
+		if string(got) != "e" {
+			t.Errorf("got %q, want \"e\"", string(got))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("readComposed is still waiting for another key")
+	}
+}
+
+func TestAutoRepeated(t *testing.T) {
+	savedRepeatMs := *repeatMs
+	defer func() { *repeatMs = savedRepeatMs }()
+	*repeatMs = 50
+
+	tests := []struct {
+		name    string
+		keys    string
+		gaps    []time.Duration // before each key but the first
+		dropped int
+	}{
+		{"pair read together", "oo", []time.Duration{10 * time.Microsecond}, 0},
+		{"buffered run", "oooo", []time.Duration{time.Microsecond, time.Microsecond, time.Microsecond}, 0},
+		{"fast pair", "oo", []time.Duration{30 * time.Millisecond}, 0},
+		{"held key", "ooooo", []time.Duration{500 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}, 2},
+		{"uneven", "ooo", []time.Duration{40 * time.Millisecond, 15 * time.Millisecond}, 0},
+		{"held backspace", "\x7f\x7f\x7f\x7f", []time.Duration{30 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastKey, lastGap, autoRepeats = 0, 0, 0
+			at := time.Now()
+			dropped := 0
+			for i, r := range tt.keys {
+				if i > 0 {
+					at = at.Add(tt.gaps[i-1])
+				}
+				if autoRepeated(r, at) {
+					dropped++
+				}
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped %d keystrokes, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// latencyLog receives one line per keystroke when -latency-log is set. It's
+// written unbuffered so nothing is lost when the program exits on Ctrl-C.
+var latencyLog *os.File
+
+func openLatencyLog(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("opening latency log: %w", err)
+	}
+	latencyLog = file
+	fmt.Fprintf(latencyLog, "# time\trune\tlatency_us\n")
+	return nil
+}
+
+// logLatency records how long the keystroke r took from being read to being
+// handled and rendered.
+func logLatency(r rune, readAt time.Time) {
+	fmt.Fprintf(latencyLog, "%s\t%q\t%d\n", readAt.Format(time.RFC3339Nano), r, time.Since(readAt).Microseconds())
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// gutterWidth is the number of columns reserved on the left for line
+// numbers. It's zero unless -linenumbers is set and the sample spans more
+// than one line, or -inline centers the sample with it.
+var gutterWidth int
+
+// multiLine is set when the sample has newlines, which the terminal can't be
+// left to wrap around, see laidOut.
+var multiLine bool
+
+func setupGutter(sample []rune) {
+	gutterWidth = 0
+	lines := 1
+	for _, r := range sample {
+		if r == '\n' {
+			lines++
+		}
+	}
+	multiLine = lines > 1
+	if *lineNumbers && multiLine {
+		gutterWidth = len(strconv.Itoa(lines)) + 1
+	}
+}
+
+// textWidth is the number of columns available to the sample itself.
+func textWidth() int {
+	return terminalWidth - gutterWidth
+}
+
+// laidOut reports whether the sample is placed on the screen row by row by
+// layoutSample, rather than printed in one go and wrapped by the terminal.
+// That's the case for multi-line samples, since in raw mode a newline only
+// moves down a row, and with -wordwrap.
+func laidOut() bool {
+	return multiLine || *wordWrap
+}
+
+// layoutSample calls visit with the row and column, relative to the typing
+// area, of every character of sample and then of the end of the sample (at
+// index len(sample)), until visit returns false. When laidOut, newlines start
+// a new row so line numbers stay aligned with the text, and with -wordwrap a
+// word that doesn't fit in the rest of the row starts the next one.
+func layoutSample(sample []rune, visit func(i, row, col int) bool) {
+	width := textWidth()
+	row, col := 0, 0
+	for j := 0; j <= len(sample); j++ {
+		if *wordWrap && col > 0 && j < len(sample) && startsWord(sample, j) && col+cellsBefore(sample[j:], wordLength(sample, j)) > width {
+			row++
+			col = 0
+		}
+		if !visit(j, row, col) || j == len(sample) {
+			return
+		}
+		row, col = nextCell(sample, j, row, col, width)
+	}
+}
+
+// nextCell returns the row and column of the character after sample[j],
+// which is drawn at row and col, in rows of width columns. It wraps the way
+// the terminal does: wide characters take two columns, so one that would
+// start in the last column of a row goes to the next, leaving that column
+// blank.
+func nextCell(sample []rune, j, row, col, width int) (int, int) {
+	switch {
+	case laidOut() && sample[j] == '\n':
+		return row + 1, 0
+	case laidOut() && sample[j] == '\t' && col < width-1:
+		col = min(nextTabStop(col), width-1)
+	default:
+		col += runeWidth(sample[j])
+	}
+	if col >= width || (col > 0 && col == width-1 && j+1 < len(sample) && runeWidth(sample[j+1]) == 2) {
+		return row + 1, 0
+	}
+	return row, col
+}
+
+// tabWidth is the number of columns between tab stops in laid out samples,
+// where a tab takes the cells up to the next one, so code keeps its
+// indentation.
+const tabWidth = 4
+
+func nextTabStop(col int) int {
+	return (col/tabWidth + 1) * tabWidth
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t'
+}
+
+func startsWord(sample []rune, i int) bool {
+	return !isSpace(sample[i]) && (i == 0 || isSpace(sample[i-1]))
+}
+
+func wordLength(sample []rune, i int) int {
+	n := 0
+	for i+n < len(sample) && !isSpace(sample[i+n]) {
+		n++
+	}
+	return n
+}
+
+// cellPosition returns the row and column, relative to the typing area, where
+// the sample character at index i is drawn.
+func cellPosition(sample []rune, i int) (row, col int) {
+	layoutSample(sample, func(j, r, c int) bool {
+		row, col = r, c
+		return j < i
+	})
+	return row, col
+}
+
+// runeWidth is the number of terminal cells r takes: two for wide and
+// fullwidth characters (CJK, fullwidth forms), none for combining marks and
+// one for everything else.
+func runeWidth(r rune) int {
+	if r < '\u0300' { // before the first combining mark, and every wide character
+		return 1
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// cellsBefore is the number of terminal cells taken by the first i
+// characters of sample.
+func cellsBefore(sample []rune, i int) int {
+	cells := 0
+	for _, r := range sample[:min(i, len(sample))] {
+		cells += runeWidth(r)
+	}
+	return cells
+}
+
+// drawLaidOutSample prints the whole sample in gray, one row at a time, with
+// its line number in the gutter if there is one.
+func drawLaidOutSample(frame *bytes.Buffer, sample []rune) {
+	line, lastRow := 0, -1
+	layoutSample(sample, func(i, row, col int) bool {
+		if i == len(sample) {
+			return false
+		}
+		newLine := i == 0 || sample[i-1] == '\n'
+		if newLine {
+			line++
+		}
+		if row != lastRow {
+			lastRow = row
+			if gutterWidth > 0 && newLine {
+				fmt.Fprintf(frame, "\033[%d;1H\033[36m%*d \033[90m", topRows()+row+1, gutterWidth-1, line) //line number
+			} else {
+				fmt.Fprintf(frame, "\033[%d;%dH\033[90m", topRows()+row+1, gutterWidth+1) //wrapped row, blank gutter
+			}
+		}
+		switch sample[i] {
+		case '\n':
+		case '\t':
+			fmt.Fprint(frame, strings.Repeat(" ", min(nextTabStop(col), textWidth()-1)-col))
+		default:
+			fmt.Fprintf(frame, "%c", sample[i])
+		}
+		return true
+	})
+	fmt.Fprintf(frame, "\033[0m")
+}
+
+// rowsNeeded is how many terminal rows the sample takes once wrapped.
+func rowsNeeded(sample []rune) int {
+	row, _ := cellPosition(sample, len(sample))
+	return row + 1
+}
+
+// topRows is the number of rows at the top of the terminal above the
+// sample, for the ruler, or with -inline whatever was on the screen already.
+func topRows() int {
+	if *inline {
+		return inlineTop
+	}
+	if *ruler {
+		return 1
+	}
+	return 0
+}
+
+// drawRuler marks the columns of the typing area on the top row: a dot for
+// each column, a + every five and the tens digit every ten.
+func drawRuler(frame *bytes.Buffer) {
+	fmt.Fprintf(frame, "\033[1;%dH\033[90m", gutterWidth+1)
+	for col := 1; col <= textWidth(); col++ {
+		switch {
+		case col%10 == 0:
+			fmt.Fprintf(frame, "%d", col/10%10)
+		case col%5 == 0:
+			fmt.Fprint(frame, "+")
+		default:
+			fmt.Fprint(frame, "·")
+		}
+	}
+	fmt.Fprint(frame, "\033[0m")
+}
+
+// reservedRows is the number of rows at the bottom of the terminal (right
+// under the sample with -inline) kept free of the sample for the status row.
+func reservedRows() int {
+	if *teach || *remaining || *goalWPM > 0 || *metronome > 0 || *eta || *race || *warnBelow > 0 || *wordPos {
+		return 1
+	}
+	return 0
+}
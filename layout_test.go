@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"ttt/typing"
+)
+
+func TestCellPosition(t *testing.T) {
+	saveGlobals(t)
+	gutterWidth, multiLine = 0, false
+	for _, test := range []struct {
+		sample           string
+		i, width         int
+		wantRow, wantCol int
+	}{
+		{"abcdef", 0, 80, 0, 0},
+		// The last cell of a full row stays on it, the next starts a row.
+		{"abcdef", 2, 3, 0, 2},
+		{"abcdef", 3, 3, 1, 0},
+		{"abcdef", 6, 3, 2, 0},
+		// The same position shrunk and grown.
+		{"abcdefghij", 7, 5, 1, 2},
+		{"abcdefghij", 7, 2, 3, 1},
+		{"abcdefghij", 7, 8, 0, 7},
+		// Wide characters take two cells, and one that would start in the
+		// last column goes to the next row.
+		{"ab漢字cd", 3, 6, 0, 4},
+		{"ab漢字cd", 3, 5, 1, 0},
+		{"ab漢字cd", 4, 5, 1, 2},
+		{"ab漢字cd", 6, 5, 1, 4},
+		{"ab漢字cd", 3, 4, 1, 0},
+		{"ab漢字cd", 6, 4, 2, 0},
+		// A combining mark takes no cell.
+		{"e\u0301x", 2, 80, 0, 1},
+	} {
+		terminalWidth = test.width
+		if row, col := cellPosition([]rune(test.sample), test.i); row != test.wantRow || col != test.wantCol {
+			t.Errorf("cellPosition(%q, %d) at %d columns = %d, %d, want %d, %d", test.sample, test.i, test.width, row, col, test.wantRow, test.wantCol)
+		}
+	}
+}
+
+// typeSample types sample from the start, checking the cursor is where
+// cellPosition puts the next character after each keystroke, and then
+// backspaces it all the same way.
+func typeSample(t *testing.T, sample string) {
+	t.Helper()
+	state = State{Session: typing.NewSession(sample, nil)}
+	typeRow, typeCol = 0, 0
+	for !state.Done() {
+		before := state.TypedIndex
+		state.Feed(state.Sample[before])
+		renderProgress(before)
+		if row, col := cellPosition(state.Sample, state.TypedIndex); typeRow != row || typeCol != col {
+			t.Fatalf("typing %q at %d columns, cursor at %d, %d after index %d, want %d, %d", sample, textWidth(), typeRow, typeCol, before, row, col)
+		}
+	}
+	for state.TypedIndex > 0 {
+		handleBackspace()
+		if row, col := cellPosition(state.Sample, state.TypedIndex); typeRow != row || typeCol != col {
+			t.Fatalf("backspacing %q at %d columns, cursor at %d, %d at index %d, want %d, %d", sample, textWidth(), typeRow, typeCol, state.TypedIndex, row, col)
+		}
+	}
+}
+
+// The cursor follows the sample as it's typed and backspaced, including
+// across rows where a wide character didn't fit in the last column.
+func TestTypingWraps(t *testing.T) {
+	saveGlobals(t)
+	out = new(bytes.Buffer)
+	gutterWidth, multiLine = 0, false
+	for _, width := range []int{3, 4, 5, 7, 80} {
+		terminalWidth, terminalHeight = width, 24
+		typeSample(t, "the quick brown fox")
+		typeSample(t, "ab漢字cd 中文的句子 xyz")
+	}
+}
+
+// Resizing wraps a sample of wide characters again at the new width, with
+// the cursor and the ghost at the cells of the characters they're on.
+func TestResizeWideCharacters(t *testing.T) {
+	saveGlobals(t)
+	out = new(bytes.Buffer)
+	gutterWidth, multiLine = 0, false
+	terminalWidth, terminalHeight = 80, 24
+	state = State{Session: typing.NewSession("ab漢字cd 中文的句子", nil)}
+	for _, r := range state.Sample[:9] {
+		state.Feed(r)
+	}
+	state.ghostIndex = 4
+	typeRow, typeCol = cellPosition(state.Sample, state.TypedIndex)
+
+	// Index 9 is 的, after 13 cells; index 4 is c, after 6. At 7 and 5
+	// columns 的 would start in the last column of a row.
+	for _, test := range []struct {
+		width              int
+		typeRow, typeCol   int
+		ghostRow, ghostCol int
+	}{
+		{80, 0, 13, 0, 6},
+		{7, 2, 0, 0, 6},
+		{6, 2, 2, 1, 0},
+		{5, 3, 0, 1, 2},
+		{4, 3, 2, 1, 2},
+	} {
+		terminalWidth = test.width
+		render(0, "resize")
+		if typeRow != test.typeRow || typeCol != test.typeCol || ghostRow != test.ghostRow || ghostCol != test.ghostCol {
+			t.Errorf("resized to %d columns, cursor at %d, %d and ghost at %d, %d, want %d, %d and %d, %d",
+				test.width, typeRow, typeCol, ghostRow, ghostCol, test.typeRow, test.typeCol, test.ghostRow, test.ghostCol)
+		}
+	}
+}
+
+// A sample that starts with newlines and has several in a row gets a row
+// for each of them, blank rows included, and typing a newline moves the
+// cursor to the start of the next row.
+func TestConsecutiveNewlines(t *testing.T) {
+	saveGlobals(t)
+	frame := new(bytes.Buffer)
+	out = frame
+	terminalWidth, terminalHeight = 80, 24
+	state = State{Session: typing.NewSession("\n\nab\n\n\ncd", nil)}
+	setupGutter(state.Sample)
+	typeRow, typeCol = 0, 0
+
+	render(0, "initial")
+	if bytes.ContainsRune(frame.Bytes(), '\n') {
+		t.Errorf("sample drawn with raw newlines: %q", frame)
+	}
+	for _, row := range []string{"\033[3;1H\033[90mab", "\033[6;1H\033[90mcd"} {
+		if !bytes.Contains(frame.Bytes(), []byte(row)) {
+			t.Errorf("sample drawn as %q, want %q in it", frame, row)
+		}
+	}
+
+	want := [][2]int{{1, 0}, {2, 0}, {2, 1}, {2, 2}, {3, 0}, {4, 0}, {5, 0}, {5, 1}, {5, 2}}
+	for i, pos := range want {
+		r := state.Sample[i]
+		if r == '\n' {
+			r = '\r'
+		}
+		handleInput(r)
+		if typeRow != pos[0] || typeCol != pos[1] {
+			t.Errorf("cursor at %d, %d after typing index %d, want %d, %d", typeRow, typeCol, i, pos[0], pos[1])
+		}
+	}
+	if !state.Done() || len(state.Typos) > 0 {
+		t.Errorf("run done %v with typos %v, want done without typos", state.Done(), state.Typos)
+	}
+}
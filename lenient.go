@@ -0,0 +1,37 @@
+package main
+
+import "unicode"
+
+// With -lenient only the letters, digits, spaces and symbols of a sample have
+// to be typed, in any case. Everything in Unicode's punctuation categories
+// (. , ; : ! ? ' " - _ ( ) [ ] { } / \ and the like, but not symbols such as
+// $ + = < >) is skipped automatically, and typing it is ignored.
+
+// lenientRune maps r to the expected character when they only differ in case.
+// It reports false for punctuation that should be ignored.
+func lenientRune(r rune) (rune, bool) {
+	expected := state.Sample[state.TypedIndex]
+	if unicode.ToLower(r) == unicode.ToLower(expected) {
+		return expected, true
+	}
+	return r, !unicode.IsPunct(r)
+}
+
+// skipPunctuation advances past the punctuation at the typing position, as if
+// it had been typed instantly.
+func skipPunctuation() {
+	for state.TypedIndex < len(state.Sample) && unicode.IsPunct(state.Sample[state.TypedIndex]) {
+		state.Skip()
+		render(state.TypedIndex, "typedIncreased")
+	}
+}
+
+// unskipPunctuation moves back over punctuation that was skipped, including
+// the character before it, so a backspace doesn't land on a position that
+// would be skipped again right away.
+func unskipPunctuation() {
+	for state.TypedIndex > 0 && unicode.IsPunct(state.Sample[state.TypedIndex]) {
+		state.Backspace()
+		render(state.TypedIndex, "typedDecreased")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"ttt/typing"
+)
+
+// With -lenient a sample ending in punctuation finishes on its last letter:
+// the skipped punctuation ends the run, and its time stops there.
+func TestLenientEndsOnPunctuation(t *testing.T) {
+	saveGlobals(t)
+	savedLenient := *lenient
+	t.Cleanup(func() { *lenient = savedLenient })
+	*lenient = true
+	out = new(bytes.Buffer)
+	terminalWidth, terminalHeight = 80, 24
+
+	state = State{Session: typing.NewSession("hello world.", nil)}
+	now := time.Unix(0, 0)
+	state.Now = func() time.Time { return now }
+	for i, r := range "hello world" {
+		if i > 0 {
+			now = now.Add(100 * time.Millisecond)
+		}
+		handleInput(r)
+		skipPunctuation()
+	}
+	if !state.Done() {
+		t.Fatalf("run not finished at index %d", state.TypedIndex)
+	}
+
+	now = now.Add(10 * time.Second)
+	if elapsed := state.Elapsed(); elapsed != time.Second {
+		t.Errorf("elapsed %v ten seconds after the run, want 1s", elapsed)
+	}
+	if wpm := state.WPM(); wpm != 120 {
+		t.Errorf("wpm %.1f ten seconds after the run, want 120", wpm)
+	}
+}
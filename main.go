@@ -4,139 +4,970 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 	"golang.org/x/term"
+	"golang.org/x/text/unicode/norm"
+
+	"ttt/typing"
 )
 
 type State struct {
-	sample     []rune
-	typedIndex int
+	*typing.Session
 	ghostIndex int
-	typos      []int
+	skipped    bool
 }
 
 type SavedSample struct {
+	Name         string `json:"name,omitempty"`
 	Text         string `json:"text"`
 	CharTimes    []int  `json:"char_times,omitempty"`
 	PersonalBest int    `json:"personal_best,omitempty"`
+
+	// LastPracticed is the date (YYYY-MM-DD) of the last completed run and
+	// Streak the number of days in a row, up to then, with one.
+	// Default marks the sample practiced when none is picked, instead of the
+	// first one.
+	Default bool `json:"default,omitempty"`
+
+	LastPracticed string `json:"last_practiced,omitempty"`
+	Streak        int    `json:"streak,omitempty"`
+
+	// Repeats keeps the PB of each -repeat count apart from the single pass.
+	Repeats map[int]*SavedSample `json:"repeats,omitempty"`
 }
 
 var (
-	state         State
-	stateMu       sync.Mutex
-	savedSamples  []SavedSample
-	hasPb         bool
-	ghostRow      int
-	ghostCol      int
-	typeRow       int
-	typeCol       int
-	terminalWidth int
-	savedSample   *SavedSample
-	oldState      *term.State
+	state          State
+	stateMu        sync.Mutex
+	savedSamples   []SavedSample
+	hasPb          bool
+	ghostTotal     time.Duration
+	pbTime         time.Duration
+	ghostRow       int
+	ghostCol       int
+	typeRow        int
+	typeCol        int
+	terminalWidth  int
+	terminalHeight int
+	savedSample    *SavedSample
+	oldState       *term.State
+	samplesPath    string
+	out            io.Writer = os.Stdout
+
+	// pendingResume is the interrupted run to carry on with in the next
+	// session, when its recovery was accepted.
+	pendingResume *recovery
+
+	// reactionTime is how long the first keystroke of the session took to
+	// come, from the sample being ready to type, for -reaction.
+	reactionTime time.Duration
+
+	// warmingUp is set while the unscored run before a sample's real attempt
+	// is being typed, see -warmup.
+	warmingUp bool
+)
+
+var (
+	showVersion = flag.Bool("version", false, "print version and build information and exit")
+	calibrate   = flag.Bool("calibrate", false, "measure how fast the terminal draws and save the minimum time between frames that -race then keeps to")
+	dashboard   = flag.Bool("dashboard", false, "print an overview of the personal bests and of the runs in the -log file and exit")
+	selftest    = flag.Bool("selftest", false, "check the terminal, the samples and the typing engine work, then exit")
+	zen         = flag.Bool("zen", false, "free typing with live wpm and no sample, Ctrl-D finishes")
+	nearMisses  = flag.Bool("nearmiss", false, "split the typos into near misses (a neighbouring key) and far ones")
+	showFingers = flag.Bool("fingers", false, "show typos grouped by the finger responsible for each key")
+	startKey    = flag.String("startkey", "", "wait for this key (enter, space, tab or a single character) before the test begins")
+	ruler       = flag.Bool("ruler", false, "show a column ruler above the sample")
+	inline      = flag.Bool("inline", false, "type a short sample on one centered line under the prompt, with the results below it, without taking over the screen")
+	pollSize    = flag.Bool("pollsize", false, "check the terminal size every second, for terminals whose resizes don't send SIGWINCH")
+	noCursorShp = flag.Bool("nocursorshape", false, "leave the cursor shape alone, for terminals that print the escape instead of a bar cursor")
+	lineNumbers = flag.Bool("linenumbers", false, "show line numbers in a left gutter for multi-line samples")
+	warnBelow   = flag.Float64("accuracy-warn", 0, "flash the status row when the accuracy so far drops below this percentage")
+	minAccuracy = flag.Float64("minaccuracy", 0, "minimum accuracy (0-100), counting corrected typos, for a run to count as a personal best")
+	samplesFlag = flag.String("samples", "", "path of the saved samples file (default $TYPINGTEST_SAMPLES, ./savedSamples.json or the user config dir)")
+	storeFormat = flag.String("format", "", "convert the samples file to json or binary (faster to load with many long samples), later runs keep its format")
+	sampleDir   = flag.String("dir", "", "load samples from the .txt files in this directory instead of savedSamples.json")
+	sampleSel   = flag.String("sample", "", "sample to practice, by index or by name (file name with -dir)")
+	sampleGen   = flag.String("generator", "", "practice a generated sample instead: words[:count], numbers[:count], quotes:file or lines:file[:from-to]")
+	saveGen     = flag.Bool("savegen", false, "add the sample made by -generator to the saved samples, to practice it again later")
+	wordsFile   = flag.String("wordlist", "", "pick the words of -generator words from this MonkeyType word list (JSON), implies -generator words")
+	punct       = flag.Bool("punct", false, "put commas and periods between the words of -generator words (after 10% and 8% of them), implies -generator words")
+	caps        = flag.Bool("caps", false, "capitalize the first word of each sentence of -generator words, implies -generator words")
+	defaultSel  = flag.String("setdefault", "", "make this sample, by index or by name, the one practiced when none is picked and exit")
+	resetSel    = flag.String("reset", "", "clear the personal best of this sample, by index or by name, or of all of them and exit")
+	editSel     = flag.String("edit", "", "open this sample, by index or by name, in $EDITOR and save the new text")
+	jsonOutput  = flag.Bool("json", false, "print the results as JSON instead of the colored summary")
+	teach       = flag.Bool("teach", false, "highlight the next character and show which keys produce it")
+	eta         = flag.Bool("eta", false, "show the estimated time left at your current pace on the status row")
+	remaining   = flag.Bool("remaining", false, "show the characters and words left on the status row")
+	wordPos     = flag.Bool("wordpos", false, "show which word of the sample is being typed, like word 12 of 80, on the status row")
+	inputPath   = flag.String("input", "", "read the keystrokes from this file instead of the keyboard, using /dev/tty as the terminal")
+	latencyPath = flag.String("latency-log", "", "write the time taken to handle and render each keystroke to this file")
+	autosave    = flag.Int("autosave", 0, "save the run in progress every this many seconds so it can be recovered after a crash")
+	drill       = flag.Bool("drill", false, "after a typo, add the word again to type it right; the added words count towards the wpm (no personal best)")
+	graphemes   = flag.Bool("graphemes", false, "count characters as they're seen (emoji with modifiers, Indic conjuncts) rather than as code points")
+	reverse     = flag.Bool("reverse", false, "type the sample backwards, from its last character to its first (no personal best)")
+	repeatMs    = flag.Int("repeatms", 0, "ignore a key held down (auto-repeat): one repeating at a steady interval under this many milliseconds, like 50")
+	softNewline = flag.Bool("softnewline", false, "accept a space (as well as Enter) where the sample has a line break, for wrapped prose")
+	lenient     = flag.Bool("lenient", false, "ignore case and skip punctuation, only the content has to be typed")
+	speedUnit   = flag.String("unit", "wpm", "speed shown in the results: wpm (words per minute) or cpm (characters per minute)")
+	wpmBasis    = flag.String("wpmbasis", "words", "what the wpm score counts as a word: words (the sample's actual words) or chars (every -worddiv correct characters, like most typing tests)")
+	loop        = flag.Bool("loop", false, "practice every sample, one after the other")
+	playlist    = flag.String("playlist", "", "practice the samples listed in this file, one per line (index or name, then xN to repeat), in order")
+	shuffle     = flag.Bool("shuffle", false, "practice every sample in random order (implies -loop)")
+	shuffleSeed = flag.Int64("seed", 0, "seed for -shuffle, -recall and -generator words, 0 picks a random one")
+	recall      = flag.Float64("recall", 0, "hide this percentage of the characters until they're typed, to practice typing a passage from memory")
+	goalWPM     = flag.Float64("goal", 0, "repeat the sample until a clean run reaches this wpm")
+	ghostLate   = flag.Bool("ghostlate", false, "hide the personal best ghost until the second half of the sample")
+	race        = flag.Bool("race", false, "show how far through the sample you and the ghost are, and whether you are on pace to win, on the status row")
+	ghostCaret  = flag.Bool("ghostcaret", false, "show the ghost as an underline on the character it types next instead of recoloring the text")
+	repeat      = flag.Int("repeat", 1, "type the sample this many times back-to-back in one run")
+	verbosity   = flag.Int("verbosity", 1, "results detail: 0 speed only, 1 speed, time and accuracy, 2 every stat")
+	rolloverMs  = flag.Int("rollover", 0, "show the share of keystrokes that came within this many milliseconds of the previous one, overlapping it (30 at -verbosity 2)")
+	wordDiv     = flag.Int("worddiv", 5, "characters per word for the speeds worked out from characters alone (the burst, the speed graph, the slowest word drill), and for the wpm score with -wpmbasis chars")
+	burstWindow = flag.Int("burst", 0, "show the fastest wpm kept up over this many characters (10 at -verbosity 2)")
+	reaction    = flag.Bool("reaction", false, "show the time from the sample appearing (or the start key) to the first keystroke")
+	perf        = flag.Bool("perf", false, "time the handling of each keystroke and warn about slow ones in the results")
+	wordWrap    = flag.Bool("wordwrap", false, "wrap the sample at word boundaries instead of splitting words at the edge")
+	focus       = flag.Bool("focus", false, "dim the text already typed, to keep the attention on what's ahead")
+	trailFlag   = flag.Bool("trail", false, "briefly highlight the characters just typed, fading to the normal color")
+	warmup      = flag.Bool("warmup", false, "type each sample once unscored before the attempt that counts")
+	readOnly    = flag.Bool("readonly", false, "never write personal bests or anything else back to the samples")
+	overwritePB = flag.Bool("overwrite-pb", false, "allow a saved personal best to be replaced by a slower one (normally they only improve)")
+	card        = flag.Bool("card", false, "print a boxed result card of each run on exit, for sharing")
+	reportPath  = flag.String("report", "", "append a markdown report of each run, with the mistyped words marked, to this file")
+	serveAddr   = flag.String("serve", "", "serve the live stats of the run as JSON over HTTP at this address (host:port, or unix:path for a socket)")
+	resultLog   = flag.String("log", "", "append every completed run as a JSON line to this file")
+	metronome   = flag.Float64("metronome", 0, "flash a beat on the status row at this many beats per minute (1-600) to pace your typing")
 )
 
 func main() {
-	if err := loadSavedSamples("savedSamples.json"); err != nil {
+	flag.Parse()
+
+	if *showVersion {
+		printVersion()
+		return
+	}
+
+	if *inputPath != "" {
+		if *calibrate {
+			fmt.Println("Error: -calibrate reads the terminal's answers, it can't be used with -input")
+			return
+		}
+		if err := openInput(*inputPath); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	if *calibrate {
+		if err := runCalibration(); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	if *selftest {
+		samplesPath = resolveSamplesPath()
+		if !runSelftest() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *zen {
+		if err := runZen(); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	if *goalWPM < 0 {
+		fmt.Println("Error: -goal must be positive")
+		return
+	}
+
+	if bpm := *metronome; bpm != 0 && !(bpm >= minBPM && bpm <= maxBPM) {
+		fmt.Printf("Error: -metronome must be between %d and %.0f bpm, or 0 for none\n", minBPM, maxBPM)
+		return
+	}
+
+	if *wordDiv < 1 {
+		fmt.Println("Error: -worddiv must be at least 1")
+		return
+	}
+
+	if *repeatMs < 0 {
+		fmt.Println("Error: -repeatms must be positive, or 0 to turn auto-repeat detection off")
+		return
+	}
+
+	if *minAccuracy < 0 || *minAccuracy > 100 {
+		fmt.Println("Error: -minaccuracy must be between 0 and 100")
+		return
+	}
+
+	if *recall < 0 || *recall > 100 {
+		fmt.Println("Error: -recall must be between 0 and 100")
+		return
+	}
+
+	if *recall > 0 && *drill {
+		fmt.Println("Error: -recall can't be used with -drill, the words it adds would have no gaps")
+		return
+	}
+
+	if *warnBelow < 0 || *warnBelow > 100 {
+		fmt.Println("Error: -accuracy-warn must be between 0 and 100")
+		return
+	}
+
+	if *inline && (*ruler || *wordWrap || *drill) {
+		fmt.Println("Error: -inline draws the sample on a single line, it can't be used with -ruler, -wordwrap or -drill")
+		return
+	}
+
+	if *storeFormat != "" {
+		switch {
+		case *storeFormat != "json" && *storeFormat != "binary":
+			fmt.Println("Error: -format must be json or binary")
+			return
+		case *sampleDir != "":
+			fmt.Println("Error: -format is for the samples file, it can't be used with -dir")
+			return
+		case *readOnly:
+			fmt.Println("Error: -format converts the samples file, it can't be used with -readonly")
+			return
+		}
+	}
+
+	if *readOnly && *autosave > 0 {
+		fmt.Println("Error: -autosave can't be used with -readonly")
+		return
+	}
+
+	if *speedUnit != "wpm" && *speedUnit != "cpm" {
+		fmt.Println("Error: -unit must be wpm or cpm")
+		return
+	}
+
+	if *wpmBasis != "words" && *wpmBasis != "chars" {
+		fmt.Println("Error: -wpmbasis must be words or chars")
+		return
+	}
+
+	if *verbosity < 0 || *verbosity > 2 {
+		fmt.Println("Error: -verbosity must be 0, 1 or 2")
+		return
+	}
+
+	if *repeat < 1 {
+		fmt.Println("Error: -repeat must be at least 1")
+		return
+	}
+
+	var startRune rune
+	if *startKey != "" {
+		var err error
+		if startRune, err = parseStartKey(*startKey); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	if *latencyPath != "" {
+		if err := openLatencyLog(*latencyPath); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer latencyLog.Close()
+	}
+
+	if *wordsFile != "" {
+		if err := loadWordList(*wordsFile); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	if *wordsFile != "" || *punct || *caps {
+		if *sampleGen == "" {
+			*sampleGen = "words"
+		} else if name, _, _ := strings.Cut(*sampleGen, ":"); name != "words" {
+			fmt.Println("Error: -wordlist, -punct and -caps are for the words generator, not", name)
+			return
+		}
+	}
+
+	if *saveGen {
+		switch {
+		case *sampleGen == "":
+			fmt.Println("Error: -savegen saves the sample of -generator, which isn't set")
+			return
+		case *sampleDir != "":
+			fmt.Println("Error: -savegen saves to the samples file, it can't be used with -dir")
+			return
+		case *readOnly:
+			fmt.Println("Error: -savegen can't be used with -readonly")
+			return
+		}
+	}
+
+	samplesPath = resolveSamplesPath()
+	minFrame = loadMinFrame()
+	if *sampleGen != "" {
+		if err := loadGenerated(*sampleGen); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if *saveGen {
+			if err := saveGenerated(); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		}
+	} else if err := loadSamples(); err != nil {
 		fmt.Println("Error:", err)
 		return
+	} else if *storeFormat != "" {
+		convertSamples(*storeFormat)
 	}
 
-	savedSample := &savedSamples[0]
+	if *dashboard {
+		if err := showDashboard(); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	if *defaultSel != "" {
+		if *readOnly {
+			fmt.Println("Error: -setdefault can't be used with -readonly")
+			return
+		}
+		if err := setDefaultSample(*defaultSel); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	if *resetSel != "" {
+		if *readOnly {
+			fmt.Println("Error: -reset can't be used with -readonly")
+			return
+		}
+		if err := resetSamples(*resetSel); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	if *editSel != "" {
+		if *readOnly {
+			fmt.Println("Error: -edit can't be used with -readonly")
+			return
+		}
+		sample, err := selectSample(*editSel)
+		if err == nil {
+			err = editSample(sample)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
 
-	initializeState(savedSample)
 	var err error
+	savedSample, err = selectSample(*sampleSel)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	var playlistOrder []int
+	if *playlist != "" {
+		if *loop || *shuffle {
+			fmt.Println("Error: -playlist sets the order itself, it can't be used with -loop or -shuffle")
+			return
+		}
+		if playlistOrder, err = loadPlaylist(*playlist); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
 	oldState, err = setupTerminal()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
-	defer term.Restore(int(os.Stdin.Fd()), oldState)
+	// Deferred first so they come out after the terminal is restored.
+	defer printCards()
+	defer term.Restore(int(tty.Fd()), oldState)
+	enableBracketedPaste()
+	defer disableBracketedPaste()
+	defer fmt.Print(showCursor)
+	if *inline && cursorShape() {
+		// The prompt comes back on the same screen, with its own cursor.
+		defer fmt.Print(defaultCursor)
+	}
+
+	var inputBuf []byte
+	rec, err := loadRecovery()
+	if err != nil {
+		fmt.Printf("Error: %v\n\r", err)
+		return
+	}
+	resumed := false
+	if rec != nil {
+		if resumed, err = promptRecovery(rec, &inputBuf); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading input", err)
+			return
+		}
+		if resumed {
+			savedSample = &savedSamples[rec.Index]
+			*repeat = rec.Repeat
+			pendingResume = rec
+		}
+	}
+
+	if !resumed && wantsMenu() {
+		if err := runMenu(&inputBuf); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading input", err)
+			return
+		}
+	}
+
+	setupResizeListener()
+
+	if *serveAddr != "" {
+		if err := startServer(*serveAddr); err != nil {
+			fmt.Printf("Error: %v\n\r", err)
+			return
+		}
+		defer stopServing()
+	}
+
+	if playlistOrder != nil {
+		queue = &sessionQueue{order: playlistOrder}
+	} else {
+		queue = newSessionQueue(sampleIndex(savedSample))
+	}
+	warmedUp := -1
+	for {
+		idx := queue.current()
+		savedSample = repeatedSample(&savedSamples[idx], *repeat)
+		if *reverse {
+			// A stand-in record, the reversed text has no PB or ghost and
+			// nothing about it is kept.
+			savedSample = &SavedSample{Name: savedSample.Name, Text: reverseText(savedSample.Text)}
+		} else if *drill {
+			// Same for drills, the words added make every run different.
+			savedSample = &SavedSample{Name: savedSample.Name, Text: savedSample.Text}
+		}
+		warmingUp = *warmup && warmedUp != idx
+		if err := runSession(&inputBuf, startRune, idx); err != nil {
+			fmt.Print(clearScreen())
+			// Input ending early leaves nothing meaningful to score or save.
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("Error: %v\n\r", err)
+			}
+			return
+		}
+		if warmingUp {
+			warmedUp = idx
+			continue
+		}
+		if state.skipped && len(queue.order) == 1 {
+			queue.skip()
+			continue
+		}
+		if *goalWPM > 0 && !state.skipped && !queue.goalReached() {
+			continue
+		}
+		if !queue.advance() {
+			break
+		}
+	}
+
+	if len(queue.results) > 1 {
+		displayQueueSummary(queue.results)
+	}
+}
+
+// runSession runs one typing test on savedSample, then shows and saves its
+// results unless the sample was skipped.
+func runSession(inputBuf *[]byte, startRune rune, index int) error {
+	// Locked for -serve, which reads the state from another goroutine.
+	stateMu.Lock()
+	initializeState(savedSample)
+	servedSample = index
+	stateMu.Unlock()
+	recallHidden = nil
+	if *recall > 0 {
+		recallHidden = hideForRecall(state.Sample, *recall)
+	}
+	if *inline {
+		if err := placeInline(); err != nil {
+			return err
+		}
+	}
+	if need := topRows() + rowsNeeded(state.Sample) + reservedRows(); need > terminalHeight {
+		return fmt.Errorf("terminal too small for this sample, need at least %d rows (have %d)", need, terminalHeight)
+	}
 
 	ghostRow, ghostCol, typeRow, typeCol = 0, 0, 0, 0
+	teachIndex = -1
+	drillWord = nil
+	runNote, loggedRun = "", time.Time{}
+	lastKey, lastGap, autoRepeats = 0, 0, 0
+	perfStats.keystrokes, perfStats.slow, perfStats.worst, perfStats.lockWait = 0, 0, 0, 0
 
 	render(0, "initial")
-	var start time.Time
-	var inputBuf []byte
-	currentCharTime := time.Now()
-	var timeDifChars time.Duration = 0
-	currentCharTimes := make([]int, len(savedSample.CharTimes))
-	copy(currentCharTimes, savedSample.CharTimes)
+	if rec := pendingResume; rec != nil && rec.Index == index {
+		resumeRun(rec)
+	}
+	pendingResume = nil
+	if *lenient {
+		skipPunctuation()
+	}
+	if *teach {
+		render(state.TypedIndex, "teach")
+	}
+	if reservedRows() > 0 {
+		render(state.TypedIndex, "status")
+	}
 
-	setupResizeListener()
+	if *startKey != "" {
+		if err := waitForStartKey(startRune, inputBuf); err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+	}
+
+	stopAutosave := func() {}
+	if *autosave > 0 {
+		stopAutosave = startAutosave(time.Duration(*autosave)*time.Second, index)
+	}
+	stopMetronome := func() {}
+	if *metronome > 0 {
+		stopMetronome = startMetronome(*metronome)
+	}
+	stopTrail := func() {}
+	if *trailFlag {
+		stopTrail = startTrail()
+	}
+	stopAccuracyWarn := func() {}
+	if *warnBelow > 0 {
+		stopAccuracyWarn = startAccuracyWarn()
+	}
 
+	readyAt := time.Now()
 	firstTypedChar := true
-	for state.typedIndex < len(state.sample) {
-		r, err := readRune(&inputBuf)
+	for state.TypedIndex < len(state.Sample) && !state.skipped {
+		runes, err := readInput(inputBuf, state.Sample[state.TypedIndex])
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "error reading input", err)
-			break
+			stopGhostAnimation()
+			stopAutosave()
+			stopMetronome()
+			stopTrail()
+			stopAccuracyWarn()
+			return fmt.Errorf("reading input: %w", err)
 		}
+		readAt := time.Now()
 
-		stateMu.Lock()
-		if firstTypedChar {
-			firstTypedChar = false
-			startGhostAnimation()
-			start = time.Now()
+		for _, r := range runes {
+			if state.TypedIndex >= len(state.Sample) || state.skipped {
+				break
+			}
+			if *repeatMs > 0 && autoRepeated(r, readAt) {
+				continue
+			}
+			if r < 32 && controlBytes[r] == controlPause {
+				if !firstTypedChar {
+					togglePause()
+				}
+				continue
+			}
+			if state.Paused() {
+				continue // only the pause key does anything while paused
+			}
+
+			stateMu.Lock()
+			lockedAt := time.Now()
+			if firstTypedChar {
+				firstTypedChar = false
+				reactionTime = readAt.Sub(readyAt)
+				startGhostAnimation()
+			}
+
+			mistakes := len(state.Mistakes)
+			handleInput(r)
+			if *drill {
+				if len(state.Mistakes) > mistakes {
+					noteDrillMistake(state.Mistakes[len(state.Mistakes)-1])
+				}
+				drillInsert()
+			}
+			if *warnBelow > 0 && len(state.Mistakes) > mistakes && runningAccuracy() < *warnBelow {
+				warnAccuracy()
+			}
+			if *lenient {
+				skipPunctuation()
+			}
+			if *teach {
+				render(state.TypedIndex, "teach")
+			}
+			if reservedRows() > 0 {
+				render(state.TypedIndex, "status")
+			}
+			stateMu.Unlock()
+
+			if showDetail(*perf) {
+				recordPerf(readAt, lockedAt)
+			}
+			if latencyLog != nil {
+				logLatency(r, readAt)
+			}
 		}
+	}
+	stopGhostAnimation()
+	stopAutosave()
+	stopMetronome()
+	stopTrail()
+	stopAccuracyWarn()
+	discardRecovery()
+	if state.skipped {
+		return nil
+	}
 
-		handleInput(r, &currentCharTime, &timeDifChars, currentCharTimes)
-		stateMu.Unlock()
+	elapsed := state.Elapsed()
+	if warmingUp {
+		if !*jsonOutput {
+			displayResults(elapsed, false)
+			if *inline {
+				return nil
+			}
+			return resultsScreen(inputBuf, func() { displayResults(elapsed, false) })
+		}
+		return nil
+	}
+	// A lenient run skips punctuation and ignores case, it can't stand in for
+	// the strict personal best or its ghost.
+	isPB := !*reverse && !*drill && !*lenient && updatePersonalBest(elapsed)
+	base := &savedSamples[index]
+	base.LastPracticed, base.Streak = updateStreak(base.LastPracticed, base.Streak, time.Now())
+	queue.record(index, state.WPM(), len(state.Typos) == 0 && state.Accuracy() >= *minAccuracy)
+	if *card {
+		cards = append(cards, resultCard(index, elapsed, isPB))
+	}
+
+	if *jsonOutput {
+		fmt.Print(clearScreen())
+		disableBracketedPaste()
+		restoreTerminal(oldState)
+		printJSONResults(elapsed, isPB)
+	} else {
+		displayResults(elapsed, isPB)
+	}
+	persistSamples()
+	if *resultLog != "" {
+		appendResultLog(*resultLog, index, elapsed, isPB)
+	}
+	if *reportPath != "" {
+		writeReport(*reportPath, savedSamples[index].Name, elapsed)
+	}
+	// -inline is for a quick check, the results are left where they are.
+	if !*jsonOutput && !*inline {
+		return resultsScreen(inputBuf, func() { displayResults(elapsed, isPB) })
+	}
+	return nil
+}
+
+func loadSamples() error {
+	var err error
+	if *sampleDir != "" {
+		err = loadSampleDir(*sampleDir)
+	} else {
+		err = loadSavedSamples(samplesPath)
+	}
+	if err == nil {
+		if n := clampCharTimes(); n > 0 {
+			fmt.Printf("Warning: %d char times in the saved samples were out of range, set to 0-%dms\n", n, maxCharTime)
+		}
+		rememberPersonalBests()
+	}
+	return err
+}
+
+// resolveSamplesPath finds the saved samples file: the -samples flag, then
+// $TYPINGTEST_SAMPLES, then savedSamples.json in the working directory if it
+// exists, and finally the one in the user's config directory
+// (~/.config/terminal_typing_test on Linux).
+func resolveSamplesPath() string {
+	if *samplesFlag != "" {
+		return *samplesFlag
+	}
+	if env := os.Getenv("TYPINGTEST_SAMPLES"); env != "" {
+		return env
+	}
+	if _, err := os.Stat("savedSamples.json"); err == nil {
+		return "savedSamples.json"
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "terminal_typing_test", "savedSamples.json")
+	}
+	return "savedSamples.json"
+}
+
+// persistSamples writes the samples back where they were loaded from, unless
+// -readonly is set or the sample was generated. No personal best is written
+// back worse than it was (see guardPersonalBests).
+func persistSamples() {
+	if *readOnly || *sampleGen != "" {
+		return
+	}
+	guardPersonalBests()
+	if *sampleDir != "" {
+		saveSampleDir(*sampleDir)
+	} else {
+		saveSamples(samplesPath)
 	}
+	rememberPersonalBests()
+}
 
-	elapsed := time.Since(start)
-	isPB := updatePersonalBest(elapsed, currentCharTimes)
+// selectSample picks the sample to practice from its index or its name. An
+// empty selection means the default sample (see -setdefault), or the first
+// one if there's none.
+func selectSample(sel string) (*SavedSample, error) {
+	if len(savedSamples) == 0 {
+		return nil, fmt.Errorf("no samples to practice")
+	}
+	if sel == "" {
+		for i := range savedSamples {
+			if savedSamples[i].Default {
+				return &savedSamples[i], nil
+			}
+		}
+		return &savedSamples[0], nil
+	}
+	if idx, err := strconv.Atoi(sel); err == nil {
+		if idx < 0 || idx >= len(savedSamples) {
+			return nil, fmt.Errorf("sample index %d out of range (0-%d)", idx, len(savedSamples)-1)
+		}
+		return &savedSamples[idx], nil
+	}
+	for i := range savedSamples {
+		if savedSamples[i].Name == sel {
+			return &savedSamples[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no sample named %q", sel)
+}
 
-	displayResults(elapsed, isPB)
-	saveSamples("savedSamples.json")
+func sampleIndex(sample *SavedSample) int {
+	for i := range savedSamples {
+		if &savedSamples[i] == sample {
+			return i
+		}
+	}
+	return 0
 }
 
 func loadSavedSamples(filename string) error {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("opening saved samples file: %w", err)
 	}
-	defer file.Close()
 
-	jsonParser := json.NewDecoder(file)
-	if err = jsonParser.Decode(&savedSamples); err != nil {
-		return fmt.Errorf("parsing config file: %w", err)
+	parse := parseSavedSamples
+	if binarySamples = isBinarySamples(data); binarySamples {
+		parse = parseBinarySamples
+	}
+	samples, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	for i, sample := range samples {
+		if strings.TrimSpace(sample.Text) == "" {
+			return fmt.Errorf("parsing %s: sample %d has no text to type", filename, i)
+		}
 	}
+	savedSamples = samples
 	return nil
 }
 
+// parseSavedSamples decodes the saved samples file, a JSON array of samples.
+// Errors say where in the file the problem is, and what to do about the usual
+// hand-editing mistakes.
+func parseSavedSamples(data []byte) ([]SavedSample, error) {
+	var samples []SavedSample
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&samples); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		// Both offsets count the byte the error is at.
+		switch {
+		case errors.As(err, &syntaxErr):
+			line, col := lineColumn(data, syntaxErr.Offset-1)
+			return nil, fmt.Errorf("line %d, column %d: %w", line, col, err)
+		case errors.As(err, &typeErr) && typeErr.Field == "" && typeErr.Value == "object":
+			return nil, fmt.Errorf("the file holds a single sample, wrap it in [ ] to make it a list of samples")
+		case errors.As(err, &typeErr):
+			line, col := lineColumn(data, typeErr.Offset-1)
+			return nil, fmt.Errorf("line %d, column %d: %w", line, col, err)
+		case errors.Is(err, io.EOF):
+			return nil, fmt.Errorf("the file is empty, it should hold a list of samples like [{\"text\": \"...\"}]")
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, fmt.Errorf("the file ends before the list of samples is closed")
+		}
+		return nil, err
+	}
+
+	end := decoder.InputOffset()
+	if _, err := decoder.Token(); err != io.EOF {
+		rest := bytes.TrimLeft(data[end:], " \t\r\n")
+		line, col := lineColumn(data, int64(len(data)-len(rest)))
+		return nil, fmt.Errorf("line %d, column %d: unexpected data after the list of samples", line, col)
+	}
+	return samples, nil
+}
+
+// lineColumn converts a byte offset in data to its 1-based line and column.
+func lineColumn(data []byte, offset int64) (line, col int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// reverseText returns text from its last character to its first, keeping
+// combining marks after the character they go on.
+func reverseText(text string) string {
+	runes := []rune(text)
+	reversed := make([]rune, 0, len(runes))
+	end := len(runes)
+	for i := len(runes) - 1; i >= 0; i-- {
+		if i > 0 && unicode.Is(unicode.Mn, runes[i]) {
+			continue
+		}
+		reversed = append(reversed, runes[i:end]...)
+		end = i
+	}
+	return string(reversed)
+}
+
+// repeatedSample returns the record holding the sample typed n times in a row,
+// separated by a space (or a newline for multi-line samples).
+func repeatedSample(base *SavedSample, n int) *SavedSample {
+	if n == 1 {
+		return base
+	}
+
+	sep := " "
+	if strings.Contains(base.Text, "\n") {
+		sep = "\n"
+	}
+	text := strings.Repeat(base.Text+sep, n-1) + base.Text
+
+	if base.Repeats == nil {
+		base.Repeats = make(map[int]*SavedSample)
+	}
+	rep, ok := base.Repeats[n]
+	if !ok || rep.Text != text {
+		rep = &SavedSample{Text: text}
+		base.Repeats[n] = rep
+	}
+	return rep
+}
+
 func initializeState(savedSample *SavedSample) {
 	state = State{
-		sample:     []rune(savedSample.Text),
-		typedIndex: 0,
-		ghostIndex: 0,
-		typos:      make([]int, 0),
+		Session: typing.NewSession(savedSample.Text, savedSample.CharTimes),
+	}
+	state.Graphemes = *graphemes
+	state.WordLen = wordLen()
+	setupGutter(state.Sample)
+	if *remaining || *wordPos {
+		wordsLeft = suffixWordCounts(state.Sample)
 	}
 
-	hasPb = len(savedSample.CharTimes) != 0
+	// Timings that don't line up with the (normalized) sample can't drive the
+	// ghost, so they're dropped along with the PB they belong to.
+	hasPb = savedSample.PersonalBest != 0 && len(savedSample.CharTimes) == len(state.Sample)
 	if !hasPb {
-		savedSample.CharTimes = make([]int, len(state.sample))
+		savedSample.PersonalBest = 0
+		savedSample.CharTimes = make([]int, len(state.Sample))
+	}
+	ghostTotal = replayTime(savedSample.CharTimes)
+	// The personal best as it was before this run, which may replace it. The
+	// ghost's replay is shorter: its char times are whole milliseconds and
+	// the trailing whitespace isn't replayed.
+	pbTime = time.Duration(savedSample.PersonalBest)
+}
+
+// replayTime is how long the ghost takes to type the whole sample.
+func replayTime(charTimes []int) time.Duration {
+	var total time.Duration
+	for _, t := range charTimes {
+		total += time.Duration(t) * time.Millisecond
+	}
+	return total
+}
+
+// maxCharTime is the longest, in milliseconds, a character of a saved run is
+// taken to have lasted. A longer pause was spent away from the keyboard.
+const maxCharTime = 5000
+
+// clampCharTimes brings the char times of every saved record into the range
+// 0-maxCharTime. Runs are clamped as they're saved, so times outside it only
+// come from a hand-edited or corrupted file, and the ghost would race through
+// the negative ones and hang for minutes on the huge ones. It returns how
+// many were out of range.
+func clampCharTimes() int {
+	clamped := 0
+	forEachRecord(func(_ pbKey, record *SavedSample) {
+		clamped += clampTimes(record.CharTimes)
+	})
+	return clamped
+}
+
+// clampTimes brings times into the range 0-maxCharTime and returns how many
+// were out of it.
+func clampTimes(times []int) int {
+	clamped := 0
+	for i, t := range times {
+		if t < 0 || t > maxCharTime {
+			times[i] = min(max(t, 0), maxCharTime)
+			clamped++
+		}
 	}
+	return clamped
 }
 
 func setupTerminal() (*term.State, error) {
 	var err error
-	_, terminalWidth, err = getTerminalSize()
+	terminalHeight, terminalWidth, err = getTerminalSize()
 	if err != nil {
 		return nil, err
 	}
 
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	oldState, err := term.MakeRaw(int(tty.Fd()))
 	if err != nil {
 		return nil, fmt.Errorf("error enabling raw mode: %w", err)
 	}
@@ -144,12 +975,15 @@ func setupTerminal() (*term.State, error) {
 }
 
 func restoreTerminal(oldState *term.State) {
-	term.Restore(int(os.Stdin.Fd()), oldState)
+	term.Restore(int(tty.Fd()), oldState)
 }
 
 func setupResizeListener() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGWINCH)
+	if *pollSize {
+		go pollTerminalSize(sigs)
+	}
 	go func() {
 		for {
 			<-sigs
@@ -160,130 +994,268 @@ func setupResizeListener() {
 
 func readRune(inputBuf *[]byte) (rune, error) {
 	b := make([]byte, 1)
-	_, err := os.Stdin.Read(b)
-	if err != nil {
-		return utf8.RuneError, err
+	for !utf8.FullRune(*inputBuf) {
+		_, err := keys.Read(b)
+		if err != nil {
+			return utf8.RuneError, err
+		}
+		*inputBuf = append(*inputBuf, b[0])
 	}
-	*inputBuf = append(*inputBuf, b[0])
 
 	r, size := utf8.DecodeRune(*inputBuf)
-	if r == utf8.RuneError && size == 1 {
-		return utf8.RuneError, nil
-	}
 	*inputBuf = (*inputBuf)[size:]
 	return r, nil
 }
 
+// readComposed reads the next keystroke, normalized to NFC. When the expected
+// character is precomposed (é) and the terminal sends it decomposed (e + ´),
+// the combining marks are read as well and the composed character returned.
+// Only marks already sent are waited for (see inputPending): a plain e typed
+// for é is returned right away, as the typo it is. Anything read that doesn't
+// compose is returned as is, to be handled in order.
+func readComposed(inputBuf *[]byte, expected rune) ([]rune, error) {
+	r, err := readRune(inputBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := []rune{r}
+	decomposed := []rune(norm.NFD.String(string(expected)))
+	if len(decomposed) > 1 && r == decomposed[0] {
+		for len(typed) < len(decomposed) && inputPending(*inputBuf) {
+			next, err := readRune(inputBuf)
+			if err != nil {
+				return nil, err
+			}
+			typed = append(typed, next)
+			if !unicode.Is(unicode.Mn, next) {
+				break
+			}
+		}
+	}
+
+	if composed := []rune(norm.NFC.String(string(typed))); len(composed) == 1 {
+		return composed, nil
+	}
+	return typed, nil
+}
+
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyRight
+	keyLeft
+)
+
+// readKey reads a rune like readRune but decodes the arrow key escape
+// sequences (ESC [ A-D) into keyUp, keyDown, keyRight and keyLeft.
+func readKey(inputBuf *[]byte) (rune, error) {
+	r, err := readRune(inputBuf)
+	if err != nil || r != 27 || !inputPending(*inputBuf) {
+		return r, err
+	}
+	if r, err = readRune(inputBuf); err != nil || r != '[' {
+		return r, err
+	}
+	if r, err = readRune(inputBuf); err != nil {
+		return r, err
+	}
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	}
+	return r, nil
+}
+
+func parseStartKey(name string) (rune, error) {
+	switch strings.ToLower(name) {
+	case "enter":
+		return 13, nil
+	case "space":
+		return ' ', nil
+	case "tab":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(name) != 1 {
+		return 0, fmt.Errorf("invalid start key %q", name)
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return r, nil
+}
+
+// waitForStartKey swallows every keystroke until the start key is pressed, so
+// reading time doesn't count and nothing gets typed into the sample.
+func waitForStartKey(startRune rune, inputBuf *[]byte) error {
+	for {
+		r, err := readRune(inputBuf)
+		if err != nil {
+			return err
+		}
+		switch {
+		case r == 3:
+			handleCtrlC()
+		case r == startRune, startRune == 13 && r == 10:
+			return nil
+		}
+	}
+}
+
+// ghostStop ends the ghost of the current session and ghostPause pauses it
+// (true) or resumes it (false). ghostDone is closed once the ghost is done
+// drawing, having replayed the whole sample or been stopped.
+var (
+	ghostStop  chan struct{}
+	ghostPause chan bool
+	ghostDone  chan struct{}
+)
+
 func startGhostAnimation() {
 	if hasPb {
+		ghostStop, ghostPause, ghostDone = make(chan struct{}), make(chan bool), make(chan struct{})
+		stop, pause, done := ghostStop, ghostPause, ghostDone
 		go func() {
-			for newGhostIndex := range ghostAnimation() {
+			defer close(done)
+			var statusAt time.Time
+			for newGhostIndex := range ghostAnimation(stop, pause) {
 				render(newGhostIndex, "ghost")
+				// A keystroke redraws the status too, the ghost only needs
+				// to once a frame.
+				if *race && time.Since(statusAt) >= minFrame {
+					statusAt = time.Now()
+					stateMu.Lock()
+					render(state.TypedIndex, "status")
+					stateMu.Unlock()
+				}
 			}
 		}()
 	}
 }
 
-func handleInput(r rune, currentCharTime *time.Time, timeDifChars *time.Duration, currentCharTimes []int) {
-	switch r {
-	case state.sample[state.typedIndex]:
-		handleCorrectInput(currentCharTime, timeDifChars, currentCharTimes)
-	case 127:
-		handleBackspace()
-	case 23:
-		handleCtrlBackspace()
-	case 8:
-		handleCtrlShiftBackspace()
-	case 3:
-		handleCtrlC()
-	case 13, 10:
-		handleNewLine(currentCharTime, timeDifChars, currentCharTimes)
-	case 27: //esc
-	default:
-		handleTypo()
+// stopGhostAnimation ends the ghost of the current session, if any, so it
+// doesn't keep drawing over the results or the next sample.
+func stopGhostAnimation() {
+	if ghostStop != nil {
+		close(ghostStop)
+		ghostStop, ghostPause, ghostDone = nil, nil, nil
 	}
 }
 
-func handleCorrectInput(currentCharTime *time.Time, timeDifChars *time.Duration, currentCharTimes []int) {
-	if slices.Contains(state.typos, state.typedIndex) {
-		idx := slices.Index(state.typos, state.typedIndex)
-		state.typos = slices.Delete(state.typos, idx, idx+1)
+// pauseGhost pauses the ghost of the current session, or resumes it, if
+// it's still going. It mustn't be called holding stateMu, which the ghost
+// takes to move on.
+func pauseGhost(paused bool) {
+	if ghostPause != nil {
+		select {
+		case ghostPause <- paused:
+		case <-ghostDone:
+		}
 	}
-	if state.typedIndex == 0 {
-		*currentCharTime = time.Now()
+}
+
+// togglePause pauses the run (Ctrl-P), or resumes it. While paused the
+// run's clock and the ghost are stopped, the cursor is hidden and every
+// other key is ignored.
+func togglePause() {
+	stateMu.Lock()
+	paused := !state.Paused()
+	if paused {
+		state.Pause()
+		fmt.Fprint(out, hideCursor)
+	} else {
+		state.Unpause()
+		fmt.Fprint(out, showCursor)
 	}
-	if len(state.typos) == 0 {
-		*timeDifChars = time.Since(*currentCharTime)
-		currentCharTimes[state.typedIndex] = int(timeDifChars.Milliseconds())
-		*currentCharTime = time.Now()
+	if reservedRows() > 0 {
+		render(state.TypedIndex, "status")
 	}
-	state.typedIndex++
-	render(state.typedIndex, "typedIncreased")
+	stateMu.Unlock()
+	pauseGhost(paused)
 }
 
-func handleBackspace() {
-	if state.typedIndex > 0 {
-		state.typedIndex--
-		render(state.typedIndex, "typedDecreased")
+func handleInput(r rune) {
+	if r < 32 {
+		switch controlBytes[r] {
+		case controlQuit:
+			handleCtrlC()
+			return
+		case controlSkip:
+			state.skipped = true
+			return
+		case controlEnter:
+			r = '\r'
+		case controlIgnore, controlPause:
+			return
+		}
 	}
-}
 
-func handleCtrlBackspace() {
-	if state.typedIndex > 0 {
-		for ok := true; ok; ok = (state.typedIndex > 0 && state.sample[state.typedIndex-1] != ' ') {
-			state.typedIndex--
-			render(state.typedIndex, "typedDecreased")
+	if *lenient {
+		var ok bool
+		if r, ok = lenientRune(r); !ok {
+			return
 		}
 	}
-}
+	// With -softnewline a line break in the sample can be typed as a space.
+	// Spaces in the sample still need a space, Enter doesn't do for them.
+	if *softNewline && r == ' ' && state.Sample[state.TypedIndex] == '\n' {
+		r = '\r'
+	}
 
-func handleCtrlShiftBackspace() {
-	for state.typedIndex > 0 {
-		state.typedIndex--
-		render(state.typedIndex, "typedDecreased")
+	switch r {
+	case typing.KeyBackspace:
+		handleBackspace()
+	default:
+		before := state.TypedIndex
+		state.Feed(r)
+		renderProgress(before)
 	}
 }
 
-func handleCtrlC() {
-	fmt.Print("\033[2J\033[H")
-	term.Restore(int(os.Stdin.Fd()), oldState)
-	os.Exit(0)
+// renderProgress draws every character between the typing position before
+// a keystroke and the current one.
+func renderProgress(before int) {
+	for i := before - 1; i >= state.TypedIndex; i-- {
+		render(i, "typedDecreased")
+	}
+	for i := before + 1; i <= state.TypedIndex; i++ {
+		render(i, "typedIncreased")
+	}
 }
 
-func handleNewLine(currentCharTime *time.Time, timeDifChars *time.Duration, currentCharTimes []int) {
-	if state.sample[state.typedIndex] == '\n' {
-		if slices.Contains(state.typos, state.typedIndex) {
-			idx := slices.Index(state.typos, state.typedIndex)
-			state.typos = slices.Delete(state.typos, idx, idx+1)
-		}
-		if state.typedIndex == 0 {
-			*currentCharTime = time.Now()
-		}
-		if len(state.typos) == 0 {
-			*timeDifChars = time.Since(*currentCharTime)
-			currentCharTimes[state.typedIndex] = int(timeDifChars.Milliseconds())
-			*currentCharTime = time.Now()
+func handleBackspace() {
+	if state.TypedIndex > 0 {
+		state.Backspace()
+		render(state.TypedIndex, "typedDecreased")
+		if *lenient {
+			unskipPunctuation()
 		}
-		state.typedIndex++
-		render(state.typedIndex, "typedIncreased")
-	} else {
-		state.typos = append(state.typos, state.typedIndex)
-		state.typedIndex++
-		render(state.typedIndex, "typedIncreased")
 	}
 }
 
-func handleTypo() {
-	if !slices.Contains(state.typos, state.typedIndex) {
-		state.typos = append(state.typos, state.typedIndex)
+func handleCtrlC() {
+	if *autosave > 0 {
+		discardRecovery()
+	}
+	fmt.Print(clearScreen() + showCursor)
+	if *inline && cursorShape() {
+		fmt.Print(defaultCursor)
 	}
-	state.typedIndex++
-	render(state.typedIndex, "typedIncreased")
+	disableBracketedPaste()
+	term.Restore(int(tty.Fd()), oldState)
+	printCards()
+	stopServing()
+	os.Exit(0)
 }
 
-func updatePersonalBest(elapsed time.Duration, currentCharTimes []int) bool {
+func updatePersonalBest(elapsed time.Duration) bool {
 	var isPB bool
-	if len(state.typos) == 0 {
+	// A sample too short to time (a single character) has no best to beat.
+	if len(state.Typos) == 0 && state.Accuracy() >= *minAccuracy && state.Timed() {
 		if !hasPb {
 			savedSample.PersonalBest = int(elapsed)
 			isPB = true
@@ -294,29 +1266,141 @@ func updatePersonalBest(elapsed time.Duration, currentCharTimes []int) bool {
 
 	if isPB {
 		savedSample.PersonalBest = int(elapsed)
-		copy(savedSample.CharTimes, currentCharTimes)
+		copy(savedSample.CharTimes, state.CharTimes)
+		clearTrailingTimes(state.Sample, savedSample.CharTimes)
+		// A pause over maxCharTime would be taken for a corrupted file.
+		clampTimes(savedSample.CharTimes)
 	}
 	return isPB
 }
 
+// clearTrailingTimes zeroes the times of the whitespace that ends a sample.
+// Those keystrokes still count towards the run's time, but the ghost replay
+// stops at the last visible character instead of pausing before an invisible
+// one.
+func clearTrailingTimes(sample []rune, charTimes []int) {
+	for i := len(sample) - 1; i >= 0 && i < len(charTimes); i-- {
+		if sample[i] != ' ' && sample[i] != '\n' && sample[i] != '\t' {
+			return
+		}
+		charTimes[i] = 0
+	}
+}
+
 func displayResults(elapsed time.Duration, isPB bool) {
-	fmt.Print("\033[2J") //clean screen
-	fmt.Printf("\033[H") //return home
-	wordCount := countWords(state.sample)
-	elapsedMinutes := elapsed.Minutes()
-	wpm := float64(wordCount) / elapsedMinutes
+	fmt.Print(clearScreen())
+	speed := state.WPM()
+	if *speedUnit == "cpm" {
+		speed = state.CPM()
+	}
 
 	var highlightColor int
 	if isPB {
 		highlightColor = 45
-	} else if len(state.typos) != 0 {
+	} else if len(state.Typos) != 0 {
 		highlightColor = 41
 	} else {
 		highlightColor = 42
 	}
 
-	fmt.Printf("\033[%dm wpm: %v\033[0m\t", highlightColor, wpm)
-	fmt.Printf("\033[%dm Time: %v\033[0m\n\r", highlightColor, elapsed)
+	if state.Timed() {
+		fmt.Printf("\033[%dm %s: %v\033[0m", highlightColor, *speedUnit, speed)
+	} else {
+		fmt.Printf("\033[%dm %s: n/a, too short to time\033[0m", highlightColor, *speedUnit)
+	}
+	if *verbosity >= 1 {
+		fmt.Printf("\t\033[%dm Time: %s\033[0m\t", highlightColor, formatElapsed(elapsed))
+		fmt.Printf("\033[%dm Accuracy: %.1f%%\033[0m", highlightColor, state.Accuracy())
+	}
+	fmt.Printf("\n\r")
+
+	if *goalWPM > 0 {
+		if queue.goalReached() {
+			fmt.Printf("\033[45m Goal of %.0f wpm reached in %d attempts!\033[0m\n\r", *goalWPM, queue.attempts)
+		} else {
+			fmt.Printf(" Attempt %d, best so far %.1f wpm, target %.0f wpm\n\r", queue.attempts, queue.best, *goalWPM)
+		}
+	}
+
+	if hasPb && *verbosity >= 1 {
+		if elapsed < pbTime {
+			fmt.Printf(" You beat the ghost by %s\n\r", formatElapsed(pbTime-elapsed))
+		} else {
+			fmt.Printf(" The ghost won by %s\n\r", formatElapsed(elapsed-pbTime))
+		}
+	}
+
+	if showDetail(*reaction) {
+		fmt.Printf(" Reaction time: %dms\n\r", reactionTime.Milliseconds())
+	}
+
+	if showDetail(*perf) {
+		displayPerf()
+	}
+
+	if showDetail(*burstWindow > 0) {
+		window := *burstWindow
+		if window <= 0 {
+			window = 10
+		}
+		if burst := burstWPM(state.CharTimes, window); burst > 0 {
+			fmt.Printf(" Burst: %.1f wpm over your fastest %d characters\n\r", burst, window)
+		}
+	}
+
+	if showDetail(*rolloverMs > 0) {
+		threshold := *rolloverMs
+		if threshold <= 0 {
+			threshold = 30
+		}
+		fmt.Printf(" Rollover: %.0f%% of keystrokes within %dms of the previous one\n\r", rolloverShare(state.Sample, state.CharTimes, threshold), threshold)
+	}
+
+	if showDetail(false) {
+		var keys []string
+		for _, k := range slowestKeys(state.Sample, state.CharTimes, 3) {
+			keys = append(keys, fmt.Sprintf("%s (%dms)", keyName(state.Sample[k]), state.CharTimes[k]))
+		}
+		if len(keys) > 0 {
+			fmt.Printf(" Slowest keys: %s\n\r", strings.Join(keys, ", "))
+		}
+	}
+
+	if streak := savedSamples[queue.current()].Streak; streak > 1 && !warmingUp && *verbosity >= 1 {
+		fmt.Printf(" %d-day streak!\n\r", streak)
+	}
+
+	if autoRepeats > 0 {
+		fmt.Printf("\033[93m Held key: %d auto-repeated keystrokes were ignored\033[0m\n\r", autoRepeats)
+	}
+
+	if runNote != "" {
+		fmt.Printf(" Note: %s\n\r", runNote)
+	}
+
+	if warmingUp {
+		fmt.Printf("\033[90m Warmup, not scored\033[0m\n\r")
+	} else if *readOnly {
+		fmt.Printf("\033[90m Read-only, results weren't saved\033[0m\n\r")
+	}
+
+	if showDetail(*nearMisses) && len(state.Mistakes) > 0 {
+		near, far := nearMissCounts(state.Sample, state.Mistakes, state.MistakeRunes)
+		fmt.Printf(" Typos: %d near misses (neighbouring key or shift), %d far\n\r", near, far)
+	}
+
+	if showDetail(*showFingers) {
+		fmt.Printf("\n\rTypos by finger (%d total):\n\r", len(state.Mistakes))
+		for _, fc := range fingerReport(state.Sample, state.Mistakes) {
+			fmt.Printf(" %-13s %d\n\r", fc.finger, fc.typos)
+		}
+	}
+}
+
+// showDetail reports whether a stat beyond the basic results is shown: when
+// its own flag is set, or always at -verbosity 2.
+func showDetail(enabled bool) bool {
+	return enabled || *verbosity >= 2
 }
 
 func saveSamples(filename string) {
@@ -327,132 +1411,274 @@ func saveSamples(filename string) {
 	}
 	defer file.Close()
 
-	jsonEncoder := json.NewEncoder(file)
-	if err = jsonEncoder.Encode(&savedSamples); err != nil {
+	if binarySamples {
+		err = encodeBinarySamples(file, savedSamples)
+	} else {
+		err = json.NewEncoder(file).Encode(&savedSamples)
+	}
+	if err != nil {
 		fmt.Println("encoding saved samples", err.Error())
 		return
 	}
 }
 
+const (
+	hideCursor    = "\033[?25l"
+	showCursor    = "\033[?25h"
+	defaultCursor = "\033[0 q"
+)
+
+// typedColor is the color of correctly typed text: white, or with -focus a
+// gray dimmer than the untyped text's, so the eye stays on what's ahead.
+func typedColor() string {
+	if *focus {
+		return "\033[38;5;238m"
+	}
+	return "\033[97m"
+}
+
+// render draws an update into a frame that's written out in a single call,
+// which keeps the terminal from showing half-drawn updates.
+//
+// Updates drawn away from the typing position (the ghost, the status row...)
+// hide the cursor while it jumps there and back, so it doesn't flicker.
 func render(newIndex int, thingToUpdate string) {
+	frame := new(bytes.Buffer)
+	defer func() { out.Write(frame.Bytes()) }()
+	switch thingToUpdate {
+	case "ghost", "teach", "status", "trail":
+		fmt.Fprint(frame, hideCursor)
+		defer fmt.Fprint(frame, showCursor)
+	}
+
 	switch thingToUpdate {
 	case "initial":
-		fmt.Print("\033[2J")                           //clean screen
-		fmt.Printf("\033[H")                           //return home
-		fmt.Printf("\033[90m%s", string(state.sample)) //prints the whole sample in gray
-		fmt.Printf("\033[H")                           //return home
-		fmt.Printf("\033[5 q")                         //change cursor to bar
+		clearTypingArea(frame)
+		if *ruler {
+			drawRuler(frame)
+		}
+		if laidOut() {
+			drawLaidOutSample(frame, state.Sample)
+		} else {
+			fmt.Fprintf(frame, "\033[%d;%dH", topRows()+1, gutterWidth+1) //start of typing area
+			fmt.Fprintf(frame, "\033[90m%s", string(state.Sample))        //prints the whole sample in gray
+		}
+		drawRecallMask(frame)
+		if *ghostCaret && hasPb {
+			moveGhostCaret(frame, 0)
+		}
+		fmt.Fprintf(frame, "\033[%d;%dH", topRows()+1, gutterWidth+1) //start of typing area
+		if cursorShape() {
+			fmt.Fprintf(frame, "\033[5 q") //change cursor to bar
+		}
 
 	case "ghost":
-		fmt.Printf("\0337")                                       //save typing position
-		fmt.Printf("\033[%d;%dH", ghostRow+1, ghostCol+1)         //position in ghost index
-		fmt.Printf("\033[95m%c\033[0m", state.sample[newIndex-1]) //write ghost char
-		fmt.Printf("\0338")                                       //back to saved typing position
-
-		if ghostCol == terminalWidth-1 {
-			ghostCol = 0
-			ghostRow++
+		ch := state.Sample[newIndex-1]
+		// With -ghostlate the ghost keeps its pace but only shows up for the
+		// second half of the sample.
+		if *ghostCaret {
+			moveGhostCaret(frame, newIndex)
+		} else if (!*ghostLate || newIndex > len(state.Sample)/2) && !isHidden(newIndex-1) {
+			fmt.Fprintf(frame, "\0337")                                                     //save typing position
+			fmt.Fprintf(frame, "\033[%d;%dH", topRows()+ghostRow+1, gutterWidth+ghostCol+1) //position in ghost index
+			fmt.Fprintf(frame, "\033[95m%c\033[0m", ch)                                     //write ghost char
+			fmt.Fprintf(frame, "\0338")                                                     //back to saved typing position
+		}
+
+		if laidOut() {
+			ghostRow, ghostCol = cellPosition(state.Sample, newIndex)
 		} else {
-			ghostCol++
+			ghostRow, ghostCol = nextCell(state.Sample, newIndex-1, ghostRow, ghostCol, textWidth())
 		}
 
 	case "typedIncreased":
-		ch := state.sample[newIndex-1]
-		if !slices.Contains(state.typos, newIndex-1) {
-			fmt.Printf("\033[97m%c\033[0m", ch)
-		} else {
-			if ch == '\n' {
-				fmt.Printf("\033[41m%c\033[0m", ' ')
-			} else if ch == ' ' {
-				fmt.Printf("\033[41m%c\033[0m", ch)
+		ch := state.Sample[newIndex-1]
+		if !slices.Contains(state.Typos, newIndex-1) {
+			if *trailFlag && ch != ' ' && ch != '\n' && ch != '\t' {
+				color := addTrail(newIndex-1, typeRow, typeCol)
+				fmt.Fprintf(frame, "\033[38;5;%dm%c\033[0m", color, ch)
+			} else if ch == '\t' {
+				// A raw tab would move the cursor to the terminal's own tab
+				// stop, the layout places the next character itself.
+				fmt.Fprint(frame, " ")
 			} else {
-				fmt.Printf("\033[91m%c\033[0m", ch)
+				fmt.Fprintf(frame, "%s%c\033[0m", typedColor(), ch)
 			}
+		} else {
+			fmt.Fprintf(frame, "\033[4;91m%c\033[0m", typoGlyph(ch))
 		}
 
-		if typeCol == terminalWidth-1 {
-			typeCol = 0
-			typeRow++
-			fmt.Printf("\033[%d;%dH", typeRow+1, typeCol+1) //begining next line
-
-		} else {
-			typeCol++
+		// The cursor is only moved when the terminal wouldn't leave it at the
+		// next position on its own: past the end of a row, or before a wide
+		// character that doesn't fit in it.
+		row, col := nextCell(state.Sample, newIndex-1, typeRow, typeCol, textWidth())
+		if laidOut() {
+			row, col = cellPosition(state.Sample, newIndex)
 		}
+		if row != typeRow || col != typeCol+runeWidth(ch) {
+			fmt.Fprintf(frame, "\033[%d;%dH", topRows()+row+1, gutterWidth+col+1) //next position in the layout
+		}
+		typeRow, typeCol = row, col
 
 	case "typedDecreased":
-		if typeCol != 0 {
-			fmt.Printf("\033[D")
-			fmt.Printf("\033[90m%c\033[0m", state.sample[newIndex])
-			fmt.Printf("\033[D")
-			typeCol--
-
-		} else if typeRow != 0 {
-			typeCol = terminalWidth - 1
+		// Within a row the character is right before the cursor, at the
+		// start of one it ends the row above. Unless the character after it
+		// is wide: then the last column of that row may have been left blank,
+		// which only the layout tells.
+		w := runeWidth(state.Sample[newIndex])
+		if laidOut() || w == 0 || (typeCol == 0 && newIndex+1 < len(state.Sample) && runeWidth(state.Sample[newIndex+1]) == 2) {
+			typeRow, typeCol = cellPosition(state.Sample, newIndex)
+		} else if typeCol >= w {
+			typeCol -= w
+		} else {
 			typeRow--
-			fmt.Printf("\033[%d;%dH", typeRow+1, typeCol+1) //position in typed index
-			fmt.Printf("\033[90m%c\033[0m", state.sample[newIndex])
-			fmt.Printf("\033[%d;%dH", typeRow+1, typeCol+1) //position in typed index
+			typeCol = textWidth() - w
+		}
+		fmt.Fprintf(frame, "\033[%d;%dH", topRows()+typeRow+1, gutterWidth+typeCol+1) //position in typed index
+		fmt.Fprint(frame, untypedCell(newIndex))
+		fmt.Fprintf(frame, "\033[%d;%dH", topRows()+typeRow+1, gutterWidth+typeCol+1) //position in typed index
+
+	case "teach":
+		if teachIndex >= newIndex && teachIndex < len(state.Sample) {
+			drawSampleChar(frame, teachIndex, "\033[90m") //back to untyped gray
 		}
+		teachIndex = newIndex
+		if newIndex < len(state.Sample) {
+			drawSampleChar(frame, newIndex, "\033[4;93m") //underline next char
+		}
+
+	case "status":
+		drawStatus(frame, statusLine())
+
+	case "trail":
+		drawTrail(frame)
 
 	case "resize":
 		stateMu.Lock()
-		fmt.Print("\033[H\033[2J") //clean and home
-		oldTerminalWidth := terminalWidth
-		_, terminalWidth, _ = getTerminalSize()
-		fmt.Printf("\033[90m%s", string(state.sample))
-		typeCellNumber := oldTerminalWidth*typeRow + typeCol
-		typeRow = (typeCellNumber / terminalWidth)
-		typeCol = (typeCellNumber % terminalWidth)
-		fmt.Printf("\033[%d;%dH", typeRow+1, typeCol+1) //position in typed index
-
-		ghostCellNumber := oldTerminalWidth*ghostRow + ghostCol
-		ghostRow = (ghostCellNumber / terminalWidth)
-		ghostCol = (ghostCellNumber % terminalWidth)
+		trail = nil
+		if _, width, err := getTerminalSize(); err == nil && width > 0 {
+			terminalWidth = width
+		}
+		if *inline {
+			centerInline()
+		}
+		clearTypingArea(frame)
+		if *ruler {
+			drawRuler(frame)
+		}
+		if laidOut() {
+			drawLaidOutSample(frame, state.Sample)
+		} else {
+			fmt.Fprintf(frame, "\033[%d;%dH\033[90m%s", topRows()+1, gutterWidth+1, string(state.Sample))
+		}
+		drawRecallMask(frame)
+		// The positions come from the sample rather than the old rows and
+		// columns, so wide characters are wrapped at the new width the way
+		// the terminal wraps them.
+		typeRow, typeCol = cellPosition(state.Sample, state.TypedIndex)
+		ghostRow, ghostCol = cellPosition(state.Sample, state.ghostIndex)
+		fmt.Fprintf(frame, "\033[%d;%dH", topRows()+typeRow+1, gutterWidth+typeCol+1) //position in typed index
 
 		stateMu.Unlock()
 	}
 }
 
-func ghostAnimation() <-chan int {
+// typoGlyph is how a typo is drawn: always as the character that was
+// expected (never what was typed), so it's clear what should have been
+// typed there. Expected whitespace gets a visible mark, · for a space, → for
+// a tab and ↵ for a newline.
+func typoGlyph(expected rune) rune {
+	switch expected {
+	case ' ':
+		return '·'
+	case '\t':
+		return '→'
+	case '\n':
+		return '↵'
+	}
+	return expected
+}
+
+// ghostNow and ghostAfter are the clock the ghost replays on, time.Now and
+// time.After unless replaced.
+var (
+	ghostNow   = time.Now
+	ghostAfter = time.After
+)
+
+// minGhostStep is the shortest the ghost waits between two characters.
+// Characters typed within the same millisecond are stored as 0ms, and
+// drawing them all at once would make the replay jump ahead in a burst.
+const minGhostStep = time.Millisecond
+
+// ghostAnimation sends the ghost's index each time it types a character, at
+// the pace of the personal best. Each step is scheduled from the start of
+// the replay rather than from the step before, so the ghost catches up on
+// the time minGhostStep borrows and on any sleep that ran late.
+//
+// While paused (true sent on pause, until false is) the clock is stopped: the
+// start of the replay moves forward by the time spent paused, so the ghost
+// picks up at the same point of the character it was waiting on.
+func ghostAnimation(stop <-chan struct{}, pause <-chan bool) <-chan int {
 	ghostChan := make(chan int)
 	go func() {
-		i := 0
-		for state.ghostIndex < len(state.sample) {
-			t := savedSamples[0].CharTimes[i]
-			time.Sleep(time.Duration(t) * time.Millisecond)
+		defer close(ghostChan)
+		i := state.ghostIndex
+		start := ghostNow()
+		var due, step time.Duration
+		for state.ghostIndex < len(state.Sample) {
+			due += time.Duration(savedSample.CharTimes[i]) * time.Millisecond
+			step = max(due, step+minGhostStep)
+			for waiting := true; waiting; {
+				select {
+				case <-ghostAfter(step - ghostNow().Sub(start)):
+					waiting = false
+				case paused := <-pause:
+					pausedAt := ghostNow()
+					for paused {
+						select {
+						case paused = <-pause:
+						case <-stop:
+							return
+						}
+					}
+					start = start.Add(ghostNow().Sub(pausedAt))
+				case <-stop:
+					return
+				}
+			}
 			i++
 			stateMu.Lock()
 			state.ghostIndex++
 			ghostChan <- state.ghostIndex
 			stateMu.Unlock()
 		}
-		close(ghostChan)
 	}()
 	return ghostChan
 }
 
-func countWords(sample []rune) int {
-	inWord := false
-	wordCount := 0
-
-	for _, r := range sample {
-		if r == ' ' || r == '\n' || r == '\t' {
-			if inWord {
-				inWord = false
-			}
-		} else {
-			if !inWord {
-				inWord = true
-				wordCount++
-			}
+// pollTerminalSize checks the size of the terminal every second and sends a
+// SIGWINCH on resized when it changed, for setups that don't deliver the
+// signal (some SSH setups, serial consoles). It asks the kernel rather than
+// the terminal, whose answer would get mixed up with the keystrokes.
+func pollTerminalSize(resized chan<- os.Signal) {
+	fd := int(tty.Fd())
+	last, _ := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	for range time.Tick(time.Second) {
+		ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+		if err != nil || (last != nil && *ws == *last) {
+			continue
+		}
+		last = ws
+		select {
+		case resized <- syscall.SIGWINCH:
+		default: // a resize is already pending
 		}
 	}
-
-	return wordCount
 }
 
 func getTerminalSize() (int, int, error) {
-	file := os.Stdin
+	file := tty
 	fd := int(file.Fd())
 
 	oldState, err := unix.IoctlGetTermios(fd, unix.TCGETS)
@@ -473,25 +1699,48 @@ func getTerminalSize() (int, int, error) {
 	response := make([]byte, 32)
 	file.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
 	n, err := reader.Read(response)
+	if err == nil {
+		if height, width, err := parseSizeReport(response[:n]); err == nil {
+			return height, width, nil
+		}
+	}
+
+	// The terminal didn't answer the query sensibly, ask the kernel instead.
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
 	if err != nil {
 		return 0, 0, err
 	}
+	if ws.Row == 0 || ws.Col == 0 {
+		return 0, 0, fmt.Errorf("unknown terminal size")
+	}
+	return int(ws.Row), int(ws.Col), nil
+}
+
+// parseSizeReport parses the answer to the \x1b[18t query, which must be
+// exactly \x1b[8;rows;colst.
+func parseSizeReport(response []byte) (int, int, error) {
+	if !bytes.HasPrefix(response, []byte("\x1b[8;")) || !bytes.HasSuffix(response, []byte("t")) {
+		return 0, 0, fmt.Errorf("unexpected response format")
+	}
 
-	trimmed := bytes.Trim(response[:n], "\x1b[t")
+	trimmed := response[len("\x1b[8;") : len(response)-1]
 	parts := strings.Split(string(trimmed), ";")
-	if len(parts) < 3 {
+	if len(parts) != 2 {
 		return 0, 0, fmt.Errorf("unexpected response format")
 	}
 
-	height, err := strconv.Atoi(parts[1])
+	height, err := strconv.Atoi(parts[0])
 	if err != nil {
 		return 0, 0, err
 	}
 
-	width, err := strconv.Atoi(parts[2])
+	width, err := strconv.Atoi(parts[1])
 	if err != nil {
 		return 0, 0, err
 	}
 
+	if height <= 0 || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid terminal size %dx%d", width, height)
+	}
 	return height, width, nil
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"ttt/typing"
+)
+
+// saveGlobals restores the globals a test changes once it's done.
+func saveGlobals(t testing.TB) {
+	prevState, prevSample, prevSamples, prevHasPb := state, savedSample, savedSamples, hasPb
+	savedOut := out
+	savedWidth, savedHeight := terminalWidth, terminalHeight
+	savedTypeRow, savedTypeCol, savedGhostRow, savedGhostCol := typeRow, typeCol, ghostRow, ghostCol
+	savedGutter, savedMultiLine := gutterWidth, multiLine
+	t.Cleanup(func() {
+		state, savedSample, savedSamples, hasPb = prevState, prevSample, prevSamples, prevHasPb
+		out = savedOut
+		terminalWidth, terminalHeight = savedWidth, savedHeight
+		typeRow, typeCol, ghostRow, ghostCol = savedTypeRow, savedTypeCol, savedGhostRow, savedGhostCol
+		gutterWidth, multiLine = savedGutter, savedMultiLine
+	})
+}
+
+// BenchmarkSession drives a long scripted run, with a typo and a backspace
+// every few words, through handleInput and the status row the way
+// runSession does (locking included), drawing into a buffered writer that's
+// thrown away. It reports what the input path costs per keystroke, to catch
+// features that slow it down. Expect a few million keystrokes/s (around 3M on
+// a current server core, some 7ms for the ~22k keystrokes of the script). A
+// drop by an order of magnitude means something made the input path slow.
+func BenchmarkSession(b *testing.B) {
+	saveGlobals(b)
+	text := strings.TrimSpace(strings.Repeat("the quick brown fox jumps over the lazy dog ", 500))
+	var script []rune
+	for i, r := range text {
+		if i%50 == 49 {
+			script = append(script, '#', typing.KeyBackspace)
+		}
+		script = append(script, r)
+	}
+
+	frames := bufio.NewWriter(io.Discard)
+	out = frames
+	terminalWidth, terminalHeight = 80, 24
+
+	b.ResetTimer()
+	for range b.N {
+		b.StopTimer()
+		state = State{Session: typing.NewSession(text, nil)}
+		setupGutter(state.Sample)
+		typeRow, typeCol = 0, 0
+		b.StartTimer()
+
+		for _, r := range script {
+			stateMu.Lock()
+			handleInput(r)
+			if reservedRows() > 0 {
+				render(state.TypedIndex, "status")
+			}
+			stateMu.Unlock()
+		}
+		frames.Flush()
+
+		if !state.Done() {
+			b.Fatalf("run not finished at index %d", state.TypedIndex)
+		}
+	}
+	b.ReportMetric(float64(b.N*len(script))/b.Elapsed().Seconds(), "keystrokes/s")
+}
+
+// The time the ghost spends paused doesn't count: its replay still takes as
+// long as its char times add up to, and it picks up at the same point of the
+// character it was waiting on. It runs on a clock the test moves by hand.
+func TestGhostPause(t *testing.T) {
+	saveGlobals(t)
+	savedNow, savedAfter := ghostNow, ghostAfter
+	t.Cleanup(func() { ghostNow, ghostAfter = savedNow, savedAfter })
+	now := time.Unix(0, 0)
+	waits, fire := make(chan time.Duration), make(chan time.Time)
+	ghostNow = func() time.Time { return now }
+	ghostAfter = func(d time.Duration) <-chan time.Time {
+		waits <- d
+		return fire
+	}
+
+	savedSample = &SavedSample{Text: "abcde", PersonalBest: 1, CharTimes: []int{0, 30, 30, 30, 30}}
+	initializeState(savedSample)
+	stop, pause := make(chan struct{}), make(chan bool)
+	defer close(stop)
+	steps := ghostAnimation(stop, pause)
+
+	// step waits out the time the ghost asks for, want, and the character.
+	step := func(want time.Duration) {
+		t.Helper()
+		if d := <-waits; d != want {
+			t.Fatalf("the ghost waited %v, want %v", d, want)
+		}
+		now = now.Add(want)
+		fire <- now
+		<-steps
+	}
+	step(time.Millisecond) // minGhostStep for the first character
+	step(29 * time.Millisecond)
+
+	// Paused 10ms into the third character, for a minute.
+	<-waits
+	now = now.Add(10 * time.Millisecond)
+	pause <- true
+	pause <- true // only taken once the ghost has read the clock and waits
+	now = now.Add(time.Minute)
+	pause <- false
+	if stateMu.Lock(); state.ghostIndex != 2 {
+		t.Errorf("the ghost went on to character %d while paused at 2", state.ghostIndex)
+	}
+	stateMu.Unlock()
+
+	step(20 * time.Millisecond)
+	step(30 * time.Millisecond)
+	step(30 * time.Millisecond)
+	if _, ok := <-steps; ok {
+		t.Fatal("the ghost went on past the end of the sample")
+	}
+	if replay := now.Sub(time.Unix(0, 0)) - time.Minute; replay != ghostTotal {
+		t.Errorf("replay took %v besides the pause, the char times add up to %v", replay, ghostTotal)
+	}
+}
+
+func TestClampCharTimes(t *testing.T) {
+	saveGlobals(t)
+	savedSamples = []SavedSample{{
+		Text:      "abcd",
+		CharTimes: []int{0, -250, int(3 * time.Minute / time.Millisecond), 120},
+		Repeats:   map[int]*SavedSample{2: {Text: "abcd abcd", CharTimes: []int{-1, 0, 0, 0, 0, 0, 0, 0, 5001}}},
+	}}
+	if n := clampCharTimes(); n != 4 {
+		t.Errorf("clamped %d char times, want 4", n)
+	}
+	if want := []int{0, 0, maxCharTime, 120}; !slices.Equal(savedSamples[0].CharTimes, want) {
+		t.Errorf("char times clamped to %v, want %v", savedSamples[0].CharTimes, want)
+	}
+	if total := replayTime(savedSamples[0].Repeats[2].CharTimes); total != maxCharTime*time.Millisecond {
+		t.Errorf("repeat record replays in %v, want %dms", total, maxCharTime)
+	}
+}
+
+// A personal best with a long pause in it is saved clamped, so it isn't
+// taken for a corrupted file the next time it's loaded.
+func TestPersonalBestClamped(t *testing.T) {
+	saveGlobals(t)
+	savedSample = &SavedSample{Text: "abcde"}
+	initializeState(savedSample)
+	now := time.Unix(0, 0)
+	state.Now = func() time.Time { return now }
+	for i, r := range "abcde" {
+		switch i {
+		case 0:
+		case 1:
+			now = now.Add(8 * time.Second)
+		default:
+			now = now.Add(100 * time.Millisecond)
+		}
+		state.Feed(r)
+	}
+
+	if !updatePersonalBest(state.Elapsed()) {
+		t.Fatal("the first clean run wasn't a personal best")
+	}
+	if want := []int{0, maxCharTime, 100, 100, 100}; !slices.Equal(savedSample.CharTimes, want) {
+		t.Errorf("char times saved as %v, want %v", savedSample.CharTimes, want)
+	}
+}
+
+// typeRun feeds text to the session in state on a clock that moves step per
+// keystroke after the first.
+func typeRun(text string, step time.Duration) {
+	now := time.Unix(0, 0)
+	state.Now = func() time.Time { return now }
+	for i, r := range text {
+		if i > 0 {
+			now = now.Add(step)
+		}
+		state.Feed(r)
+	}
+}
+
+// The whitespace that ends a sample still counts towards the run's time, but
+// the ghost replaying the saved char times stops at the last visible
+// character instead of pausing on it.
+func TestClearTrailingTimes(t *testing.T) {
+	saveGlobals(t)
+	savedSample = &SavedSample{Text: "abc \n "}
+	initializeState(savedSample)
+	typeRun("abc \r ", 100*time.Millisecond)
+
+	if elapsed := state.Elapsed(); !updatePersonalBest(elapsed) || elapsed != 500*time.Millisecond {
+		t.Fatalf("a clean %v run wasn't saved as the personal best", elapsed)
+	}
+	if want := []int{0, 100, 100, 0, 0, 0}; !slices.Equal(savedSample.CharTimes, want) {
+		t.Errorf("char times saved as %v, want %v", savedSample.CharTimes, want)
+	}
+	if replay := replayTime(savedSample.CharTimes); replay != 200*time.Millisecond {
+		t.Errorf("the ghost replays in %v, want 200ms, up to the c", replay)
+	}
+}
+
+// Input that ends before the first keystroke leaves nothing to score: no
+// personal best is set and the samples file isn't rewritten.
+func TestInputEndsBeforeFirstKey(t *testing.T) {
+	saveGlobals(t)
+	savedPath := samplesPath
+	t.Cleanup(func() { samplesPath = savedPath })
+
+	samplesPath = filepath.Join(t.TempDir(), "samples.json")
+	content := []byte(`[{"text":"hello"}]`)
+	if err := os.WriteFile(samplesPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadSavedSamples(samplesPath); err != nil {
+		t.Fatal(err)
+	}
+	w := pipeKeys(t)
+	w.Close()
+
+	out = new(bytes.Buffer)
+	terminalWidth, terminalHeight = 80, 24
+	savedSample = &savedSamples[0]
+	var inputBuf []byte
+	if err := runSession(&inputBuf, 0, 0); !errors.Is(err, io.EOF) {
+		t.Fatalf("runSession returned %v, want the end of input", err)
+	}
+
+	if savedSamples[0].PersonalBest != 0 {
+		t.Errorf("personal best set to %v", time.Duration(savedSamples[0].PersonalBest))
+	}
+	if data, err := os.ReadFile(samplesPath); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("samples file rewritten as %s (%v)", data, err)
+	}
+}
+
+// countingWriter counts the writes to it.
+type countingWriter struct{ writes int }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+// Every update render draws reaches the terminal in a single write, so it
+// never shows half of one.
+func TestRenderWritesOnce(t *testing.T) {
+	saveGlobals(t)
+	terminalWidth, terminalHeight = 80, 24
+	state = State{Session: typing.NewSession("hello world", nil)}
+	setupGutter(state.Sample)
+	typeRow, typeCol, ghostRow, ghostCol = 0, 0, 0, 0
+
+	w := new(countingWriter)
+	out = w
+	for _, update := range []struct {
+		index int
+		kind  string
+	}{
+		{0, "initial"},
+		{1, "typedIncreased"},
+		{1, "ghost"},
+		{1, "status"},
+		{0, "typedDecreased"},
+		{0, "resize"},
+	} {
+		w.writes = 0
+		render(update.index, update.kind)
+		if w.writes != 1 {
+			t.Errorf("render(%d, %q) wrote %d times, want once", update.index, update.kind, w.writes)
+		}
+	}
+}
+
+func TestParseSizeReport(t *testing.T) {
+	if rows, cols, err := parseSizeReport([]byte("\x1b[8;24;80t")); err != nil || rows != 24 || cols != 80 {
+		t.Errorf("parseSizeReport of a 80x24 report = %d, %d, %v", rows, cols, err)
+	}
+	for _, response := range []string{
+		"",
+		"t",
+		"\x1b[8;t",
+		"\x1b[8;24;80",
+		"\x1b[4;24;80t",
+		"\x1b[8;24t",
+		"\x1b[8;24;80;1t",
+		"\x1b[8;;80t",
+		"\x1b[8;x;80t",
+		"\x1b[8;24;80xt",
+		"\x1b[8;0;80t",
+		"\x1b[8;24;0t",
+		"\x1b[8;-24;80t",
+	} {
+		if rows, cols, err := parseSizeReport([]byte(response)); err == nil {
+			t.Errorf("parseSizeReport(%q) = %d, %d, want an error", response, rows, cols)
+		}
+	}
+}
+
+func TestParseSavedSamples(t *testing.T) {
+	samples, err := parseSavedSamples([]byte("[\n  {\"text\": \"one\"},\n  {\"text\": \"two\", \"personal_best\": 1500}\n]\n"))
+	if err != nil || len(samples) != 2 || samples[0].Text != "one" || samples[1].Text != "two" || samples[1].PersonalBest != 1500 {
+		t.Errorf("parsing two samples = %+v, %v", samples, err)
+	}
+
+	for _, test := range []struct {
+		name, data, wantErr string
+	}{
+		{"empty", "", "the file is empty"},
+		{"blank", " \n\t\n", "the file is empty"},
+		{"single object", `{"text": "one"}`, "the file holds a single sample, wrap it in [ ]"},
+		{"unclosed", `[{"text": "one"}`, "the file ends before the list of samples is closed"},
+		{"trailing data", "[{\"text\": \"one\"}]\n  x", "line 2, column 3: unexpected data after the list of samples"},
+		{"second list", `[{"text": "one"}] []`, "line 1, column 19: unexpected data after the list of samples"},
+		{"trailing comma", "[{\"text\": \"one\"},\n {\"text\": \"two\",}\n]", "line 2, column 17: invalid character '}'"},
+		{"missing comma", "[{\"text\": \"one\"}\n {\"text\": \"two\"}]", "line 2, column 2: invalid character '{'"},
+		{"wrong type", `[{"text": 1}]`, "line 1, column 11: json: cannot unmarshal number"},
+	} {
+		if _, err := parseSavedSamples([]byte(test.data)); err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+			t.Errorf("%s: parseSavedSamples(%q) = %v, want an error starting %q", test.name, test.data, err, test.wantErr)
+		}
+	}
+}
+
+// A sample too short to time has no speed and never sets a personal best,
+// however quickly it's typed.
+func TestShortSampleNotTimed(t *testing.T) {
+	saveGlobals(t)
+	for _, test := range []struct {
+		text  string
+		timed bool
+	}{
+		{"a", false},
+		{"abcd", false},
+		{"abcde", true},
+	} {
+		savedSample = &SavedSample{Text: test.text}
+		initializeState(savedSample)
+		typeRun(test.text, 100*time.Millisecond)
+
+		if isPB := updatePersonalBest(state.Elapsed()); isPB != test.timed || (savedSample.PersonalBest > 0) != test.timed {
+			t.Errorf("%q typed in %v: personal best %v saved as %d, want one only if it's timed (%v)",
+				test.text, state.Elapsed(), isPB, savedSample.PersonalBest, test.timed)
+		}
+	}
+}
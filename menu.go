@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type menuOption struct {
+	key   rune
+	label string
+	value *bool
+}
+
+var menuOptions = []menuOption{
+	{'l', "line numbers", lineNumbers},
+	{'f', "typos by finger", showFingers},
+}
+
+// wantsMenu reports whether the session should be set up interactively.
+// Choosing where samples come from (-dir) still shows the menu, any other
+// flag bypasses it.
+func wantsMenu() bool {
+	bypass := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "dir" {
+			bypass = true
+		}
+	})
+	return !bypass
+}
+
+// runMenu lets the user pick the sample and toggle options with the arrow
+// keys, and sets savedSample once Enter is pressed.
+func runMenu(inputBuf *[]byte) error {
+	selected := 0
+	if def, err := selectSample(""); err == nil {
+		selected = sampleIndex(def)
+	}
+	for {
+		renderMenu(selected)
+
+		r, err := readKey(inputBuf)
+		if err != nil {
+			return err
+		}
+		switch r {
+		case keyUp, 'k':
+			if selected > 0 {
+				selected--
+			}
+		case keyDown, 'j':
+			if selected < len(savedSamples)-1 {
+				selected++
+			}
+		case 13, 10:
+			savedSample = &savedSamples[selected]
+			return nil
+		case 3, 'q':
+			handleCtrlC()
+		default:
+			for _, opt := range menuOptions {
+				if r == opt.key {
+					*opt.value = !*opt.value
+				}
+			}
+		}
+	}
+}
+
+func renderMenu(selected int) {
+	fmt.Print("\033[2J") //clean screen
+	fmt.Printf("\033[H") //return home
+	fmt.Printf("\033[97m Terminal Typing Test\033[0m\n\r\n\r")
+
+	for i, sample := range savedSamples {
+		label := sample.Name
+		if label == "" {
+			label = menuPreview(sample.Text, terminalWidth-12)
+		}
+		if i == selected {
+			fmt.Printf("\033[7m > %3d  %s\033[0m\n\r", i, label)
+		} else {
+			fmt.Printf("\033[90m   %3d  %s\033[0m\n\r", i, label)
+		}
+	}
+
+	fmt.Printf("\n\r")
+	for _, opt := range menuOptions {
+		mark := ' '
+		if *opt.value {
+			mark = 'x'
+		}
+		fmt.Printf(" [%c] %s (%c)\n\r", mark, opt.label, opt.key)
+	}
+	fmt.Printf("\n\r\033[90m up/down choose  enter start  q quit\033[0m")
+}
+
+// menuPreview flattens a sample to a single line of at most width runes.
+func menuPreview(text string, width int) string {
+	preview := []rune(strings.Join(strings.Fields(text), " "))
+	if width < 4 {
+		width = 4
+	}
+	if len(preview) > width {
+		return string(preview[:width-3]) + "..."
+	}
+	return string(preview)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "time"
+
+// metronomeBeat is true for the short moment after each metronome tick, while
+// the beat marker on the status row is lit.
+var metronomeBeat bool
+
+const metronomeFlash = 100 * time.Millisecond
+
+// The metronome's range, in beats per minute. Past maxBPM the beats would
+// come faster than the marker can flash.
+const (
+	minBPM = 1
+	maxBPM = float64(time.Minute / metronomeFlash)
+)
+
+// startMetronome flashes the beat marker on the status row bpm times per
+// minute until the returned function is called. It's only pacing feedback,
+// the scoring doesn't know about it.
+func startMetronome(bpm float64) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Duration(float64(time.Minute) / bpm))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			drawBeat(true)
+
+			select {
+			case <-time.After(metronomeFlash):
+			case <-stop:
+				return
+			}
+			drawBeat(false)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		metronomeBeat = false
+	}
+}
+
+func drawBeat(lit bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	metronomeBeat = lit
+	render(state.TypedIndex, "status")
+}
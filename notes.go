@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+	"unicode"
+)
+
+// maxNoteLen is the most characters a note on an attempt can have.
+const maxNoteLen = 60
+
+// runNote is the note added to the last run from the results screen, shown
+// with its results.
+var runNote string
+
+// addNote is the results screen action that asks for a short note on the
+// run ("tired", "new keyboard") and stores it with the run in the -log file.
+func addNote(inputBuf *[]byte) error {
+	if warmingUp || *resultLog == "" || loggedRun.IsZero() {
+		fmt.Print("\n\r\033[90m Notes are kept with the runs in the -log file, and warmups aren't logged. Press a key\033[0m")
+		_, err := readKey(inputBuf)
+		return err
+	}
+
+	note, ok, err := readNote(inputBuf)
+	if err != nil || !ok {
+		return err
+	}
+	if err := appendNote(*resultLog, loggedRun, note); err != nil {
+		fmt.Printf("\n\r\033[91m %v. Press a key\033[0m", err)
+		_, err = readKey(inputBuf)
+		return err
+	}
+	runNote = note
+	return nil
+}
+
+// readNote reads a line of text in raw mode, echoing it on a prompt. Enter
+// accepts it and Esc cancels (ok is false then).
+func readNote(inputBuf *[]byte) (note string, ok bool, err error) {
+	var text []rune
+	fmt.Print("\n\r Note (enter to save, esc to cancel): ")
+	for {
+		r, err := readKey(inputBuf)
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case r == 13 || r == 10:
+			return string(text), true, nil
+		case r == 27:
+			return "", false, nil
+		case r == 3:
+			handleCtrlC()
+		case r == 127 || r == 8:
+			if len(text) > 0 {
+				text = text[:len(text)-1]
+				fmt.Print("\b \b")
+			}
+		case unicode.IsPrint(r) && len(text) < maxNoteLen:
+			text = append(text, r)
+			fmt.Printf("%c", r)
+		}
+	}
+}
+
+// appendNote adds note to the run logged at time run in the -log file at
+// path, as a line of its own after it.
+func appendNote(path string, run time.Time, note string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening results log: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(logNote{For: run, Note: note}); err != nil {
+		return fmt.Errorf("writing results log: %w", err)
+	}
+	return nil
+}
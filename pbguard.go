@@ -0,0 +1,77 @@
+package main
+
+import (
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/unicode/norm"
+)
+
+// pbKey identifies a personal best: the sample's index and the -repeat count
+// it was set with.
+type pbKey struct {
+	index  int
+	repeat int
+}
+
+type storedPB struct {
+	text         string
+	personalBest int
+	charTimes    []int
+}
+
+// storedPBs are the personal bests as loaded, which persistSamples never
+// writes back worse (see guardPersonalBests).
+var storedPBs map[pbKey]storedPB
+
+// forEachRecord calls fn with every record of the samples that can hold a
+// personal best: each sample and each of its -repeat records.
+func forEachRecord(fn func(key pbKey, record *SavedSample)) {
+	for i := range savedSamples {
+		fn(pbKey{i, 1}, &savedSamples[i])
+		for n, rep := range savedSamples[i].Repeats {
+			fn(pbKey{i, n}, rep)
+		}
+	}
+}
+
+// rememberPersonalBests keeps a copy of the usable personal bests just
+// loaded. Those whose timings don't match their sample are left out, they're
+// dropped anyway when the sample is practiced.
+func rememberPersonalBests() {
+	storedPBs = make(map[pbKey]storedPB)
+	forEachRecord(func(key pbKey, record *SavedSample) {
+		if record.PersonalBest != 0 && len(record.CharTimes) == len([]rune(norm.NFC.String(record.Text))) {
+			storedPBs[key] = storedPB{record.Text, record.PersonalBest, slices.Clone(record.CharTimes)}
+		}
+	})
+}
+
+// forgetPersonalBest stops guarding the personal bests of the sample at
+// index, for when they're cleared on purpose (-reset, -edit).
+func forgetPersonalBest(index int) {
+	for key := range storedPBs {
+		if key.index == index {
+			delete(storedPBs, key)
+		}
+	}
+}
+
+// guardPersonalBests puts back any personal best, and the char times the
+// ghost replays, that's about to be saved worse than it was loaded: only a
+// faster run may replace them. -overwrite-pb turns this off. A sample whose
+// text has changed since isn't guarded, its old times no longer apply.
+func guardPersonalBests() {
+	if *overwritePB {
+		return
+	}
+	forEachRecord(func(key pbKey, record *SavedSample) {
+		stored, ok := storedPBs[key]
+		if !ok || record.Text != stored.text {
+			return
+		}
+		improved := record.PersonalBest != 0 && record.PersonalBest < stored.personalBest
+		if !improved && (record.PersonalBest != stored.personalBest || !slices.Equal(record.CharTimes, stored.charTimes)) {
+			record.PersonalBest = stored.personalBest
+			record.CharTimes = slices.Clone(stored.charTimes)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// A run slower than the personal best doesn't replace it, and neither does
+// one written over it anyway, as a buggy feature might: neither the PB nor
+// its char times are saved worse.
+func TestGuardPersonalBests(t *testing.T) {
+	saveGlobals(t)
+	savedStored, savedOverwrite := storedPBs, *overwritePB
+	t.Cleanup(func() { storedPBs, *overwritePB = savedStored, savedOverwrite })
+	*overwritePB = false
+
+	best := []int{0, 100, 100, 100, 100}
+	savedSamples = []SavedSample{{Text: "abcde", PersonalBest: int(400 * time.Millisecond), CharTimes: slices.Clone(best)}}
+	rememberPersonalBests()
+	savedSample = &savedSamples[0]
+	initializeState(savedSample)
+
+	now := time.Unix(0, 0)
+	state.Now = func() time.Time {
+		now = now.Add(300 * time.Millisecond)
+		return now
+	}
+	for _, r := range "abcde" {
+		state.Feed(r)
+	}
+	if !state.Timed() {
+		t.Fatal("the run is too short to be timed, it could never be a personal best")
+	}
+	if updatePersonalBest(state.Elapsed()) {
+		t.Fatalf("a %v run replaced a 400ms personal best", state.Elapsed())
+	}
+
+	savedSample.PersonalBest = int(state.Elapsed())
+	copy(savedSample.CharTimes, state.CharTimes)
+	guardPersonalBests()
+	if savedSample.PersonalBest != int(400*time.Millisecond) || !slices.Equal(savedSample.CharTimes, best) {
+		t.Errorf("personal best saved as %v %v, want 400ms %v", time.Duration(savedSample.PersonalBest), savedSample.CharTimes, best)
+	}
+}
+
+// With -overwrite-pb whatever is about to be saved is kept.
+func TestGuardPersonalBestsOverwrite(t *testing.T) {
+	saveGlobals(t)
+	savedStored, savedOverwrite := storedPBs, *overwritePB
+	t.Cleanup(func() { storedPBs, *overwritePB = savedStored, savedOverwrite })
+	*overwritePB = true
+
+	savedSamples = []SavedSample{{Text: "abc", PersonalBest: int(200 * time.Millisecond), CharTimes: []int{0, 100, 100}}}
+	rememberPersonalBests()
+	savedSamples[0].PersonalBest = int(time.Second)
+	guardPersonalBests()
+	if savedSamples[0].PersonalBest != int(time.Second) {
+		t.Errorf("personal best put back to %v with -overwrite-pb", time.Duration(savedSamples[0].PersonalBest))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// slowKeystroke is how long handling a keystroke can take, from being read
+// to being rendered, before -perf counts it as slow: about one frame at 60Hz.
+const slowKeystroke = 16 * time.Millisecond
+
+// perfStats collects the keystroke timings of the current session for -perf.
+var perfStats struct {
+	keystrokes, slow int
+	worst            time.Duration
+
+	// lockWait is the longest a keystroke waited for stateMu, held by the
+	// ghost, the resize handler or another timer.
+	lockWait time.Duration
+}
+
+// recordPerf times a keystroke read at readAt, that got the state lock at
+// lockedAt and has just been handled.
+func recordPerf(readAt, lockedAt time.Time) {
+	took := time.Since(readAt)
+	perfStats.keystrokes++
+	perfStats.worst = max(perfStats.worst, took)
+	perfStats.lockWait = max(perfStats.lockWait, lockedAt.Sub(readAt))
+	if took > slowKeystroke {
+		perfStats.slow++
+	}
+}
+
+func displayPerf() {
+	color := "\033[90m"
+	if perfStats.slow > 0 {
+		color = "\033[93m"
+	}
+	fmt.Printf("%s %d of %d keystrokes took over %v to handle (worst %v, longest lock wait %v)\033[0m\n\r",
+		color, perfStats.slow, perfStats.keystrokes, slowKeystroke,
+		perfStats.worst.Round(time.Microsecond), perfStats.lockWait.Round(time.Microsecond))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadPlaylist reads the practice order from a -playlist file. Each line is a
+// sample, by index or by name, optionally followed by how many times in a
+// row to practice it:
+//
+//	# warm up, then the hard ones
+//	0
+//	code snippet x3
+//	7 x2
+//
+// Blank lines and lines starting with # are ignored.
+func loadPlaylist(path string) ([]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening playlist: %w", err)
+	}
+	defer file.Close()
+
+	var order []int
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		sel, count, err := playlistEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("playlist line %d: %w", line, err)
+		}
+		sample, err := selectSample(sel)
+		if err != nil {
+			return nil, fmt.Errorf("playlist line %d: %w", line, err)
+		}
+		for range count {
+			order = append(order, sampleIndex(sample))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading playlist: %w", err)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("playlist %s has no samples", path)
+	}
+	return order, nil
+}
+
+// playlistEntry splits a playlist line into the sample and its count, 1
+// unless the line ends in " xN".
+func playlistEntry(entry string) (string, int, error) {
+	i := strings.LastIndex(entry, " x")
+	if i < 0 {
+		return entry, 1, nil
+	}
+	count, err := strconv.Atoi(entry[i+2:])
+	if err != nil {
+		// Not a count, just a name with " x" in it.
+		return entry, 1, nil
+	}
+	if count < 1 {
+		return "", 0, fmt.Errorf("count x%d must be at least 1", count)
+	}
+	return strings.TrimSpace(entry[:i]), count, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import "fmt"
+
+// sessionQueue is the order in which samples are practiced. Without -loop or
+// -playlist it holds a single sample, which Ctrl-N swaps for the next one.
+type sessionQueue struct {
+	order   []int
+	pos     int
+	results []queueResult
+
+	// attempts and best are for the current sample, for -goal.
+	attempts int
+	best     float64
+}
+
+type queueResult struct {
+	index int
+	wpm   float64
+	clean bool
+}
+
+var queue *sessionQueue
+
+func newSessionQueue(first int) *sessionQueue {
+	if !*loop && !*shuffle {
+		return &sessionQueue{order: []int{first}}
+	}
+
+	order := make([]int, len(savedSamples))
+	for i := range order {
+		order[i] = (first + i) % len(savedSamples)
+	}
+	if *shuffle {
+		seededRand().Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+	return &sessionQueue{order: order}
+}
+
+func (q *sessionQueue) current() int {
+	return q.order[q.pos]
+}
+
+func (q *sessionQueue) hasNext() bool {
+	return q.pos+1 < len(q.order)
+}
+
+// advance moves on to the next queued sample, reporting false at the end.
+func (q *sessionQueue) advance() bool {
+	q.attempts, q.best = 0, 0
+	q.pos++
+	return q.pos < len(q.order)
+}
+
+// skip replaces the current sample with the one after it.
+func (q *sessionQueue) skip() {
+	q.attempts, q.best = 0, 0
+	q.order[q.pos] = (q.order[q.pos] + 1) % len(savedSamples)
+}
+
+// record adds a finished run. A clean run left no typos behind and met
+// -minaccuracy.
+func (q *sessionQueue) record(index int, wpm float64, clean bool) {
+	q.results = append(q.results, queueResult{index, wpm, clean})
+	q.attempts++
+	if clean && wpm > q.best {
+		q.best = wpm
+	}
+}
+
+// goalReached reports whether the last run was clean and at least -goal wpm.
+func (q *sessionQueue) goalReached() bool {
+	if len(q.results) == 0 {
+		return false
+	}
+	last := q.results[len(q.results)-1]
+	return last.clean && last.wpm >= *goalWPM
+}
+
+func displayQueueSummary(results []queueResult) {
+	best, worst := results[0], results[0]
+	total := 0.0
+	for _, res := range results {
+		total += res.wpm
+		if res.wpm > best.wpm {
+			best = res
+		}
+		if res.wpm < worst.wpm {
+			worst = res
+		}
+	}
+
+	fmt.Print("\033[2J") //clean screen
+	fmt.Printf("\033[H") //return home
+	fmt.Printf("\033[97m Session summary, %d samples\033[0m\n\r\n\r", len(results))
+	fmt.Printf(" average wpm: %.1f\n\r", total/float64(len(results)))
+	fmt.Printf(" best:  sample %d, %.1f wpm\n\r", best.index, best.wpm)
+	fmt.Printf(" worst: sample %d, %.1f wpm\n\r", worst.index, worst.wpm)
+}
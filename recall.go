@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+)
+
+// recallHidden marks the characters of the sample hidden by -recall, which
+// show as blank slots until they're typed.
+var recallHidden []bool
+
+// recallRand picks the hidden characters, from -seed so a drill can be
+// repeated with the same gaps.
+var recallRand *rand.Rand
+
+// hideForRecall picks pct percent of the sample's characters, whitespace
+// aside, to hide.
+func hideForRecall(sample []rune, pct float64) []bool {
+	if recallRand == nil {
+		recallRand = seededRand()
+	}
+
+	var candidates []int
+	for i, r := range sample {
+		if !isSpace(r) {
+			candidates = append(candidates, i)
+		}
+	}
+	hidden := make([]bool, len(sample))
+	n := int(float64(len(candidates))*pct/100 + 0.5)
+	for _, k := range recallRand.Perm(len(candidates))[:n] {
+		hidden[candidates[k]] = true
+	}
+	return hidden
+}
+
+// isHidden reports whether the character at i is hidden by -recall, which it
+// is until typed.
+func isHidden(i int) bool {
+	return i < len(recallHidden) && recallHidden[i] && i >= state.TypedIndex
+}
+
+// untypedCell is how the sample character at i looks before it's typed:
+// gray, or a blank slot if -recall hides it.
+func untypedCell(i int) string {
+	if isHidden(i) {
+		return "\033[4;90m \033[0m"
+	}
+	ch := state.Sample[i]
+	if ch == '\n' || ch == '\t' {
+		ch = ' '
+	}
+	return fmt.Sprintf("\033[90m%c\033[0m", ch)
+}
+
+// drawRecallMask blanks out the hidden characters still to type, over a
+// sample just drawn whole.
+func drawRecallMask(frame *bytes.Buffer) {
+	for i := range recallHidden {
+		if isHidden(i) {
+			drawGlyph(frame, i, "\033[4;90m", ' ')
+		}
+	}
+}
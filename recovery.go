@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// recovery is the in-progress state of a run, written every -autosave
+// seconds so a crash doesn't lose a long session.
+type recovery struct {
+	Index      int    `json:"index"`
+	Repeat     int    `json:"repeat"`
+	Text       string `json:"text"`
+	TypedIndex int    `json:"typed_index"`
+	Typos      []int  `json:"typos"`
+	Mistakes   []int  `json:"mistakes"`
+	// MistakeRunes is missing from files written by older versions.
+	MistakeRunes string    `json:"mistake_runes,omitempty"`
+	CharTimes    []int     `json:"char_times"`
+	ElapsedMs    int64     `json:"elapsed_ms"`
+	SavedAt      time.Time `json:"saved_at"`
+}
+
+func recoveryPath() string {
+	if *sampleDir != "" {
+		return filepath.Join(*sampleDir, ".ttt_recovery.json")
+	}
+	return strings.TrimSuffix(samplesPath, ".json") + ".recovery.json"
+}
+
+// loadRecovery returns the interrupted run left behind by a previous
+// session, or nil if there's none (or it no longer matches its sample).
+func loadRecovery() (*recovery, error) {
+	file, err := os.Open(recoveryPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening recovery file: %w", err)
+	}
+	defer file.Close()
+
+	var rec recovery
+	if err = json.NewDecoder(file).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("parsing recovery file: %w", err)
+	}
+	if rec.Index < 0 || rec.Index >= len(savedSamples) || rec.Repeat < 1 ||
+		rec.TypedIndex < 0 || rec.TypedIndex >= len([]rune(rec.Text)) {
+		discardRecovery()
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func writeRecovery(rec recovery) {
+	file, err := os.OpenFile(recoveryPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	json.NewEncoder(file).Encode(rec)
+}
+
+func discardRecovery() {
+	os.Remove(recoveryPath())
+}
+
+// startAutosave snapshots the running session every interval. The returned
+// function stops it and waits for any snapshot being written.
+func startAutosave(interval time.Duration, index int) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+
+			stateMu.Lock()
+			rec := recovery{
+				Index:        index,
+				Repeat:       *repeat,
+				Text:         string(state.Sample),
+				TypedIndex:   state.TypedIndex,
+				Typos:        append([]int(nil), state.Typos...),
+				Mistakes:     append([]int(nil), state.Mistakes...),
+				MistakeRunes: string(state.MistakeRunes),
+				CharTimes:    append([]int(nil), state.CharTimes...),
+				ElapsedMs:    state.Elapsed().Milliseconds(),
+				SavedAt:      time.Now(),
+			}
+			stateMu.Unlock()
+
+			writeRecovery(rec)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// resumeRun restores the interrupted run rec into the state just set up for
+// its sample, and draws what had been typed (and how far the ghost had got)
+// so typing carries on where it stopped. A run whose sample has changed
+// since starts over.
+func resumeRun(rec *recovery) {
+	if rec.Text != string(state.Sample) || len(rec.CharTimes) != len(state.Sample) {
+		return
+	}
+	for _, i := range append(append([]int(nil), rec.Typos...), rec.Mistakes...) {
+		if i < 0 || i >= rec.TypedIndex {
+			return
+		}
+	}
+
+	state.TypedIndex = rec.TypedIndex
+	state.Typos = append(state.Typos[:0], rec.Typos...)
+	state.Mistakes = append(state.Mistakes[:0], rec.Mistakes...)
+	// Mistakes and MistakeRunes go in pairs, older recovery files didn't keep
+	// what was typed.
+	state.MistakeRunes = []rune(rec.MistakeRunes)
+	for len(state.MistakeRunes) < len(state.Mistakes) {
+		state.MistakeRunes = append(state.MistakeRunes, '?')
+	}
+	state.MistakeRunes = state.MistakeRunes[:len(state.Mistakes)]
+	copy(state.CharTimes, rec.CharTimes)
+	elapsed := time.Duration(rec.ElapsedMs) * time.Millisecond
+	state.Resume(elapsed)
+
+	if hasPb {
+		var ghostTime time.Duration
+		for state.ghostIndex < len(state.Sample) {
+			ghostTime += time.Duration(savedSample.CharTimes[state.ghostIndex]) * time.Millisecond
+			if ghostTime > elapsed {
+				break
+			}
+			state.ghostIndex++
+			render(state.ghostIndex, "ghost")
+		}
+	}
+	renderProgress(0)
+}
+
+// promptRecovery asks whether to go back to an interrupted run. Resuming
+// selects its sample again and carries on from where it was interrupted; the
+// recovery file is kept until that sample is finished or skipped.
+func promptRecovery(rec *recovery, inputBuf *[]byte) (bool, error) {
+	fmt.Print("\033[2J") //clean screen
+	fmt.Printf("\033[H") //return home
+	progress := 0
+	if n := len([]rune(rec.Text)); n > 0 {
+		progress = 100 * rec.TypedIndex / n
+	}
+	fmt.Printf(" A run of sample %d was interrupted at %d%% (%s).\n\r", rec.Index, progress, rec.SavedAt.Format(time.DateTime))
+	fmt.Printf(" [r]esume or [d]iscard?")
+
+	for {
+		r, err := readRune(inputBuf)
+		if err != nil {
+			return false, err
+		}
+		switch r {
+		case 'r', 'R', 13, 10:
+			return true, nil
+		case 'd', 'D':
+			discardRecovery()
+			return false, nil
+		case 3:
+			handleCtrlC()
+		}
+	}
+}
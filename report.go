@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// markdownReport lays out the finished run for -report: the results and the
+// sample in a fenced block, with a line of carets under each word that had
+// a typo (even if fixed later).
+func markdownReport(name string, elapsed time.Duration) string {
+	mistyped := make(map[int]bool)
+	for _, idx := range state.Mistakes {
+		mistyped[idx] = true
+	}
+
+	var b strings.Builder
+	if name == "" {
+		name = "Typing test"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", name)
+	fmt.Fprintf(&b, "_%s_\n\n", time.Now().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "| %s | Accuracy | Time | Typos |\n", *speedUnit)
+	fmt.Fprintf(&b, "|---|---|---|---|\n")
+	speed := state.WPM()
+	if *speedUnit == "cpm" {
+		speed = state.CPM()
+	}
+	fmt.Fprintf(&b, "| %.1f | %.1f%% | %s | %d |\n\n", speed, state.Accuracy(), formatElapsed(elapsed), len(state.Mistakes))
+
+	fmt.Fprintf(&b, "```text\n")
+	var words []string
+	start := 0
+	for start <= len(state.Sample) {
+		end := start
+		for end < len(state.Sample) && state.Sample[end] != '\n' {
+			end++
+		}
+		line := state.Sample[start:end]
+
+		marks := []rune(strings.Repeat(" ", len(line)))
+		marked := false
+		for i := 0; i < len(line); {
+			if line[i] == ' ' || line[i] == '\t' {
+				i++
+				continue
+			}
+			j := i
+			hasTypo := false
+			for j < len(line) && line[j] != ' ' && line[j] != '\t' {
+				hasTypo = hasTypo || mistyped[start+j]
+				j++
+			}
+			// A typo on the space or newline after a word counts for the word.
+			hasTypo = hasTypo || mistyped[start+j]
+			if hasTypo {
+				for k := i; k < j; k++ {
+					marks[k] = '^'
+				}
+				marked = true
+				words = append(words, string(line[i:j]))
+			}
+			i = j
+		}
+
+		fmt.Fprintf(&b, "%s\n", string(line))
+		if marked {
+			fmt.Fprintf(&b, "%s\n", strings.TrimRight(string(marks), " "))
+		}
+		start = end + 1
+	}
+	fmt.Fprintf(&b, "```\n")
+
+	if len(words) > 0 {
+		fmt.Fprintf(&b, "\nMistyped: %s\n", strings.Join(words, ", "))
+	}
+	return b.String()
+}
+
+// writeReport appends the run's report to path, so every run of a -loop,
+// -playlist or -goal session is kept, each under its own heading.
+func writeReport(path, name string, elapsed time.Duration) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Println("opening report", err.Error())
+		return
+	}
+	defer file.Close()
+
+	report := markdownReport(name, elapsed)
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		report = "\n" + report
+	}
+	if _, err := file.WriteString(report); err != nil {
+		fmt.Println("writing report", err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+	"unicode"
+)
+
+type runResult struct {
+	WPM          float64 `json:"wpm"`
+	CPM          float64 `json:"cpm"`
+	ElapsedMs    int64   `json:"elapsed_ms"`
+	Accuracy     float64 `json:"accuracy"`
+	Typos        int     `json:"typos"`
+	PersonalBest bool    `json:"personal_best"`
+
+	// Keystrokes is the number of characters in the sample, including
+	// spaces, newlines and punctuation, for computing other speed metrics
+	// (e.g. for code, where words per minute says little).
+	Keystrokes int `json:"keystrokes"`
+}
+
+func newRunResult(elapsed time.Duration, isPB bool) runResult {
+	return runResult{
+		WPM:          state.WPM(),
+		CPM:          state.CPM(),
+		ElapsedMs:    elapsed.Milliseconds(),
+		Accuracy:     state.Accuracy(),
+		Typos:        len(state.Mistakes),
+		PersonalBest: isPB,
+		Keystrokes:   len(state.Sample),
+	}
+}
+
+func printJSONResults(elapsed time.Duration, isPB bool) {
+	out, err := json.Marshal(newRunResult(elapsed, isPB))
+	if err != nil {
+		fmt.Println("encoding results", err.Error())
+		return
+	}
+	fmt.Println(string(out))
+}
+
+// logEntry is a line of the -log file: a run's results with when it
+// finished and which sample it was, and the note added to it, if any.
+type logEntry struct {
+	Time   time.Time `json:"time"`
+	Sample int       `json:"sample"`
+	Note   string    `json:"note,omitempty"`
+	runResult
+}
+
+// logNote is a line of the -log file that adds a note to the run logged at
+// time For. It's appended after the run like any other line, the log is
+// never rewritten, and a later note on the same run replaces an earlier one.
+type logNote struct {
+	For  time.Time `json:"note_for"`
+	Note string    `json:"note"`
+}
+
+// loggedRun is the time the last run was logged at, which a note on it goes
+// by. It's zero if the run wasn't logged.
+var loggedRun time.Time
+
+// appendResultLog adds the finished run as one JSON line at the end of the
+// file at path, creating it if needed. It's independent of the saved
+// samples, every completed run is logged whether it's a personal best or not.
+func appendResultLog(path string, index int, elapsed time.Duration, isPB bool) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Println("opening results log", err.Error())
+		return
+	}
+	defer file.Close()
+
+	entry := logEntry{Time: time.Now(), Sample: index, runResult: newRunResult(elapsed, isPB)}
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		fmt.Println("writing results log", err.Error())
+		return
+	}
+	loggedRun = entry.Time
+}
+
+// burstWPM is the fastest speed kept up over window consecutive characters,
+// in words (of -worddiv characters) per minute: the run's ceiling, even if it
+// slowed down elsewhere. It's zero if the sample is shorter than window.
+func burstWPM(charTimes []int, window int) float64 {
+	if window < 1 || len(charTimes) < window {
+		return 0
+	}
+	sum := 0
+	for _, t := range charTimes[:window] {
+		sum += t
+	}
+	fastest := sum
+	for i := window; i < len(charTimes); i++ {
+		sum += charTimes[i] - charTimes[i-window]
+		fastest = min(fastest, sum)
+	}
+	if fastest <= 0 {
+		return 0
+	}
+	return charsWPM(window, fastest)
+}
+
+// charsWPM is the speed of typing chars characters in ms milliseconds, in
+// words per minute, counting a word as -worddiv characters (5 by default, the
+// usual standard) whatever the actual words are.
+func charsWPM(chars, ms int) float64 {
+	return float64(chars) / float64(*wordDiv) / (float64(ms) / 60000)
+}
+
+// wordLen is the Session.WordLen for -wpmbasis: -worddiv when the wpm score
+// counts characters, zero when it counts words.
+func wordLen() int {
+	if *wpmBasis == "chars" {
+		return *wordDiv
+	}
+	return 0
+}
+
+// rolloverShare is the percentage of keystrokes that came less than
+// threshold milliseconds after the one before, too soon for the previous key
+// to have been released: rollover, the overlapping of keys fast typists do.
+// The first character, timed from nothing, isn't counted, nor is skipped
+// punctuation with -lenient, recorded as 0ms.
+func rolloverShare(sample []rune, charTimes []int, threshold int) float64 {
+	counted, rolled := 0, 0
+	for i := 1; i < len(charTimes) && i < len(sample); i++ {
+		if *lenient && unicode.IsPunct(sample[i]) {
+			continue
+		}
+		counted++
+		if charTimes[i] < threshold {
+			rolled++
+		}
+	}
+	if counted == 0 {
+		return 0
+	}
+	return 100 * float64(rolled) / float64(counted)
+}
+
+// slowestKeys returns the positions of the n characters of the sample that
+// took longest to type, slowest first. Whitespace isn't counted, pausing
+// between words says more about the next word than about the space.
+func slowestKeys(sample []rune, charTimes []int, n int) []int {
+	var keys []int
+	for i, r := range sample {
+		if i < len(charTimes) && charTimes[i] > 0 && r != ' ' && r != '\n' && r != '\t' {
+			keys = append(keys, i)
+		}
+	}
+	sort.SliceStable(keys, func(a, b int) bool { return charTimes[keys[a]] > charTimes[keys[b]] })
+	return keys[:min(n, len(keys))]
+}
+
+// formatElapsed shows a duration to the hundredth of a second, as "12.34s"
+// under a minute and "1:03.48" from there on.
+func formatElapsed(d time.Duration) string {
+	cs := d.Round(10*time.Millisecond).Milliseconds() / 10
+	if cs < 6000 {
+		return fmt.Sprintf("%d.%02ds", cs/100, cs%100)
+	}
+	return fmt.Sprintf("%d:%02d.%02d", cs/6000, cs/100%60, cs%100)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ttt/typing"
+)
+
+func TestFormatElapsed(t *testing.T) {
+	for _, test := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0.00s"},
+		{40 * time.Millisecond, "0.04s"},
+		{996 * time.Millisecond, "1.00s"},
+		{12345 * time.Millisecond, "12.35s"},
+		{59994 * time.Millisecond, "59.99s"},
+		{59995 * time.Millisecond, "1:00.00"},
+		{61500 * time.Millisecond, "1:01.50"},
+		{12*time.Minute + 5*time.Second + 70*time.Millisecond, "12:05.07"},
+	} {
+		if got := formatElapsed(test.d); got != test.want {
+			t.Errorf("formatElapsed(%v) = %q, want %q", test.d, got, test.want)
+		}
+	}
+}
+
+// Notes are appended to the -log file as lines of their own, the runs before
+// them are never rewritten, and reading the log puts each note (the latest,
+// if a run got several) on its run.
+func TestRunNotes(t *testing.T) {
+	saveGlobals(t)
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	logRun := func(index int) time.Time {
+		state = State{Session: typing.NewSession("hello there", nil)}
+		typeRun("hello there", 100*time.Millisecond)
+		appendResultLog(path, index, state.Elapsed(), false)
+		return loggedRun
+	}
+
+	first := logRun(0)
+	second := logRun(1)
+	if first.IsZero() || second.IsZero() || first.Equal(second) {
+		t.Fatalf("runs logged at %v and %v, want two different times", first, second)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []struct {
+		run  time.Time
+		text string
+	}{
+		{first, "tired"},
+		{second, "new keyboard"},
+		{first, "very tired"},
+	} {
+		if err := appendNote(path, note.run, note.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.HasPrefix(after, before) {
+		t.Errorf("log rewritten when adding notes:\n%s\nwas:\n%s", after, before)
+	}
+
+	entries, err := readResultLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Sample != 0 || entries[1].Sample != 1 {
+		t.Fatalf("read %+v, want the two runs", entries)
+	}
+	if entries[0].Note != "very tired" || entries[1].Note != "new keyboard" {
+		t.Errorf("notes read as %q and %q, want %q and %q", entries[0].Note, entries[1].Note, "very tired", "new keyboard")
+	}
+}
+
+// -wpmbasis chars counts every -worddiv characters as a word, for a personal
+// best as for a run, instead of the sample's actual words.
+func TestPbWPMBasis(t *testing.T) {
+	savedBasis := *wpmBasis
+	t.Cleanup(func() { *wpmBasis = savedBasis })
+	sample := &SavedSample{Text: "extraordinary things", PersonalBest: int(6 * time.Second)}
+
+	for _, test := range []struct {
+		basis string
+		wpm   float64
+	}{
+		{"words", 20},
+		{"chars", 40},
+	} {
+		*wpmBasis = test.basis
+		if wpm := pbWPM(sample); wpm != test.wpm {
+			t.Errorf("-wpmbasis %s: pb wpm %.1f, want %.1f", test.basis, wpm, test.wpm)
+		}
+	}
+}
+
+// -report keeps every run of a session, not just the last one.
+func TestWriteReportAppends(t *testing.T) {
+	saveGlobals(t)
+	path := filepath.Join(t.TempDir(), "report.md")
+	state = State{Session: typing.NewSession("hello world", nil)}
+
+	writeReport(path, "first", time.Second)
+	writeReport(path, "second", time.Second)
+	report, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := bytes.Index(report, []byte("# first\n")), bytes.Index(report, []byte("\n\n# second\n"))
+	if first != 0 || second < 0 {
+		t.Errorf("report doesn't hold both runs in order:\n%s", report)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type resultsAction struct {
+	key   rune
+	label string
+	run   func(inputBuf *[]byte) error
+}
+
+// resultsActions are the views reachable with a key from the results screen.
+var resultsActions = []resultsAction{
+	{'d', "full diff", showDiff},
+	{'g', "wpm graph", showGraph},
+	{'n', "add a note", addNote},
+	{'w', "drill slowest word", drillSlowestWord},
+}
+
+// resultsScreen waits on the results for a key: one of the resultsActions
+// opens its view and comes back to the results, anything else moves on.
+func resultsScreen(inputBuf *[]byte, redraw func()) error {
+	for {
+		var hints []string
+		for _, action := range resultsActions {
+			hints = append(hints, fmt.Sprintf("%c: %s", action.key, action.label))
+		}
+		next := "quit"
+		if warmingUp {
+			next = "scored attempt"
+		} else if *goalWPM > 0 && !queue.goalReached() {
+			next = "try again"
+		} else if queue.hasNext() {
+			next = "next sample"
+		}
+		fmt.Printf("\n\r\033[90m %s  any other key: %s\033[0m", strings.Join(hints, "  "), next)
+
+		r, err := readKey(inputBuf)
+		if err != nil {
+			return nil
+		}
+		action := findResultsAction(r)
+		if action == nil {
+			fmt.Printf("\n\r")
+			return nil
+		}
+		if err := action.run(inputBuf); err != nil {
+			return nil
+		}
+		redraw()
+	}
+}
+
+func findResultsAction(r rune) *resultsAction {
+	for i := range resultsActions {
+		if resultsActions[i].key == r {
+			return &resultsActions[i]
+		}
+	}
+	return nil
+}
+
+// diffLines lays out the whole sample with the wrong character typed at each
+// position where a typo was made (even if fixed later) shown in red instead
+// of the expected one.
+func diffLines(width int) []string {
+	wrong := make(map[int]rune)
+	for i, idx := range state.Mistakes {
+		if i < len(state.MistakeRunes) {
+			wrong[idx] = state.MistakeRunes[i]
+		}
+	}
+
+	var lines []string
+	var line strings.Builder
+	col := 0
+	for i, ch := range state.Sample {
+		if typed, ok := wrong[i]; ok {
+			if typed == ' ' || typed == '\n' || typed == '\r' || typed == '\t' {
+				typed = '_'
+			}
+			fmt.Fprintf(&line, "\033[97;41m%c\033[0m", typed)
+		} else if ch == '\n' {
+			lines = append(lines, line.String())
+			line.Reset()
+			col = 0
+			continue
+		} else {
+			fmt.Fprintf(&line, "\033[90m%c\033[0m", ch)
+		}
+
+		col++
+		if col == width {
+			lines = append(lines, line.String())
+			line.Reset()
+			col = 0
+		}
+	}
+	return append(lines, line.String())
+}
+
+// showDiff shows diffLines a screen at a time, scrolled with the arrow keys
+// or j/k, until q or Esc.
+func showDiff(inputBuf *[]byte) error {
+	lines := diffLines(terminalWidth)
+	height := terminalHeight - 1
+	top := 0
+	for {
+		fmt.Print("\033[2J") //clean screen
+		fmt.Printf("\033[H") //return home
+		end := min(top+height, len(lines))
+		fmt.Print(strings.Join(lines[top:end], "\n\r"))
+		fmt.Printf("\033[%d;1H\033[90m line %d-%d of %d, up/down scroll, q back\033[0m", terminalHeight, top+1, end, len(lines))
+
+		r, err := readKey(inputBuf)
+		if err != nil {
+			return err
+		}
+		switch r {
+		case keyUp, 'k':
+			top = max(top-1, 0)
+		case keyDown, 'j', ' ':
+			top = max(min(top+1, len(lines)-height), 0)
+		case 'q', 27, 13, 10:
+			return nil
+		case 3:
+			handleCtrlC()
+		}
+	}
+}
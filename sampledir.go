@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sampleDirIndex holds the PBs and char times of a sample directory, keyed by
+// file name, so the .txt files themselves are never rewritten.
+const sampleDirIndex = ".ttt_index.json"
+
+type sampleRecord struct {
+	CharTimes    []int `json:"char_times,omitempty"`
+	PersonalBest int   `json:"personal_best,omitempty"`
+
+	LastPracticed string `json:"last_practiced,omitempty"`
+	Streak        int    `json:"streak,omitempty"`
+	Default       bool   `json:"default,omitempty"`
+
+	Repeats map[int]*SavedSample `json:"repeats,omitempty"`
+}
+
+func loadSampleDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading samples directory: %w", err)
+	}
+
+	index := make(map[string]sampleRecord)
+	file, err := os.Open(filepath.Join(dir, sampleDirIndex))
+	if err == nil {
+		defer file.Close()
+		if err = json.NewDecoder(file).Decode(&index); err != nil {
+			return fmt.Errorf("parsing samples index: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("opening samples index: %w", err)
+	}
+
+	savedSamples = nil
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".txt" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("reading sample %s: %w", entry.Name(), err)
+		}
+		text := strings.TrimRight(string(content), "\r\n")
+		if strings.TrimSpace(text) == "" {
+			continue // a placeholder, nothing to type
+		}
+
+		sample := SavedSample{Name: entry.Name(), Text: text}
+		// Drop timings recorded before the file was edited.
+		if rec, ok := index[entry.Name()]; ok {
+			if len(rec.CharTimes) == len([]rune(text)) {
+				sample.CharTimes = rec.CharTimes
+				sample.PersonalBest = rec.PersonalBest
+			}
+			sample.LastPracticed, sample.Streak = rec.LastPracticed, rec.Streak
+			sample.Default = rec.Default
+			sample.Repeats = rec.Repeats
+		}
+		savedSamples = append(savedSamples, sample)
+	}
+
+	if len(savedSamples) == 0 {
+		return fmt.Errorf("no .txt samples with text in %s", dir)
+	}
+	return nil
+}
+
+func saveSampleDir(dir string) {
+	index := make(map[string]sampleRecord)
+	for _, sample := range savedSamples {
+		if sample.PersonalBest != 0 || sample.Streak != 0 || sample.Default || len(sample.Repeats) != 0 {
+			index[sample.Name] = sampleRecord{
+				CharTimes:     sample.CharTimes,
+				PersonalBest:  sample.PersonalBest,
+				LastPracticed: sample.LastPracticed,
+				Streak:        sample.Streak,
+				Default:       sample.Default,
+				Repeats:       sample.Repeats,
+			}
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, sampleDirIndex), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Println("opening samples index for writing", err.Error())
+		return
+	}
+	defer file.Close()
+
+	if err = json.NewEncoder(file).Encode(index); err != nil {
+		fmt.Println("encoding samples index", err.Error())
+		return
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Empty and blank .txt files in a sample directory are skipped, there's
+// nothing in them to type.
+func TestLoadSampleDirSkipsEmpty(t *testing.T) {
+	saveGlobals(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"hello.txt": "hello there\n",
+		"empty.txt": "",
+		"blank.txt": "\n  \n",
+		"notes.md":  "not a sample",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := loadSampleDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(savedSamples) != 1 || savedSamples[0].Name != "hello.txt" || savedSamples[0].Text != "hello there" {
+		t.Errorf("loaded %+v, want only hello.txt", savedSamples)
+	}
+
+	os.Remove(filepath.Join(dir, "hello.txt"))
+	if err := loadSampleDir(dir); err == nil || !strings.Contains(err.Error(), "no .txt samples") {
+		t.Errorf("loading a directory of empty samples = %v, want an error", err)
+	}
+}
+
+// A samples file with an empty sample is refused, naming the sample.
+func TestLoadSavedSamplesRejectsEmpty(t *testing.T) {
+	saveGlobals(t)
+	path := filepath.Join(t.TempDir(), "samples.json")
+	for _, test := range []struct {
+		data, wantErr string
+	}{
+		{`[{"text": "one"}, {"text": ""}]`, "sample 1 has no text"},
+		{`[{"text": " \n"}]`, "sample 0 has no text"},
+		{`[{"name": "untitled"}]`, "sample 0 has no text"},
+	} {
+		if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadSavedSamples(path); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("loading %s = %v, want an error with %q", test.data, err, test.wantErr)
+		}
+	}
+}
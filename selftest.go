@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"ttt/typing"
+)
+
+// selftestChecks are run in order by -selftest. Each returns a short note
+// on success, or what went wrong.
+var selftestChecks = []struct {
+	name string
+	run  func() (string, error)
+}{
+	{"terminal", checkTerminal},
+	{"samples", checkSamples},
+	{"engine", checkEngine},
+	{"render", checkRender},
+}
+
+// runSelftest runs every check with scripted input instead of the keyboard
+// and prints OK or FAIL for each, to confirm the build works on a machine.
+// It reports whether they all passed.
+func runSelftest() bool {
+	passed := true
+	for _, check := range selftestChecks {
+		note, err := check.run()
+		if err != nil {
+			passed = false
+			fmt.Printf("%-10s FAIL  %v\n", check.name, err)
+			continue
+		}
+		fmt.Printf("%-10s OK    %s\n", check.name, note)
+	}
+	return passed
+}
+
+func checkTerminal() (string, error) {
+	fd := int(tty.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%s is not a terminal", tty.Name())
+	}
+	height, width, err := getTerminalSize()
+	if err != nil {
+		return "", fmt.Errorf("getting the terminal size: %w", err)
+	}
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("enabling raw mode: %w", err)
+	}
+	if err := term.Restore(fd, saved); err != nil {
+		return "", fmt.Errorf("restoring the terminal: %w", err)
+	}
+	note := fmt.Sprintf("%dx%d, raw mode works", width, height)
+	if basicTerminal() {
+		note += fmt.Sprintf(", TERM=%q gets no xterm escapes", os.Getenv("TERM"))
+	}
+	return note, nil
+}
+
+func checkSamples() (string, error) {
+	if err := loadSamples(); err != nil {
+		return "", err
+	}
+	where := samplesPath
+	if *sampleDir != "" {
+		where = *sampleDir
+	}
+	return fmt.Sprintf("%d samples in %s", len(savedSamples), where), nil
+}
+
+// checkEngine types a scripted run, with a typo fixed by a backspace, on a
+// clock that moves 100ms per keystroke and checks the results.
+func checkEngine() (string, error) {
+	s := typing.NewSession("hi there", nil)
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time {
+		now = now.Add(100 * time.Millisecond)
+		return now
+	}
+	for _, r := range "hx\x7fi there" {
+		s.Feed(r)
+	}
+
+	switch {
+	case !s.Done():
+		return "", fmt.Errorf("run not finished at index %d", s.TypedIndex)
+	case len(s.Typos) != 0 || len(s.Mistakes) != 1 || s.Mistakes[0] != 1:
+		return "", fmt.Errorf("typos %v and mistakes %v, want none and [1]", s.Typos, s.Mistakes)
+	case s.Elapsed() != 900*time.Millisecond:
+		return "", fmt.Errorf("elapsed %v, want 900ms", s.Elapsed())
+	case math.Abs(s.Accuracy()-800.0/9) > 0.01:
+		return "", fmt.Errorf("accuracy %.2f, want 88.89", s.Accuracy())
+	case math.Abs(s.WPM()-2/0.015) > 0.01:
+		return "", fmt.Errorf("wpm %.2f, want 133.33", s.WPM())
+	}
+	return fmt.Sprintf("%.1f wpm, %.1f%% accuracy", s.WPM(), s.Accuracy()), nil
+}
+
+// checkRender draws a run into a buffer instead of the terminal and checks
+// the cursor follows the typing across a wrapped row and a backspace.
+func checkRender() (string, error) {
+	savedState, savedOut := state, out
+	savedWidth, savedHeight := terminalWidth, terminalHeight
+	savedRow, savedCol := typeRow, typeCol
+	savedGutter, savedMultiLine := gutterWidth, multiLine
+	defer func() {
+		state, out = savedState, savedOut
+		terminalWidth, terminalHeight = savedWidth, savedHeight
+		typeRow, typeCol = savedRow, savedCol
+		gutterWidth, multiLine = savedGutter, savedMultiLine
+	}()
+
+	frames := new(bytes.Buffer)
+	out = frames
+	terminalWidth, terminalHeight = 5, 4
+	state = State{Session: typing.NewSession("abc def", nil)}
+	setupGutter(state.Sample)
+	typeRow, typeCol = 0, 0
+
+	render(0, "initial")
+	if !strings.Contains(frames.String(), "abc def") {
+		return "", fmt.Errorf("initial render doesn't show the sample")
+	}
+	for _, r := range "abc de" {
+		state.Feed(r)
+		render(state.TypedIndex, "typedIncreased")
+	}
+	if typeRow != 1 || typeCol != 1 {
+		return "", fmt.Errorf("cursor at row %d col %d after wrapping, want 1, 1", typeRow, typeCol)
+	}
+	state.Backspace()
+	render(state.TypedIndex, "typedDecreased")
+	state.Backspace()
+	render(state.TypedIndex, "typedDecreased")
+	if typeRow != 0 || typeCol != 4 {
+		return "", fmt.Errorf("cursor at row %d col %d after backspacing, want 0, 4", typeRow, typeCol)
+	}
+	return fmt.Sprintf("%d bytes drawn", frames.Len()), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// liveStats is what -serve answers with: the state of the run in progress.
+type liveStats struct {
+	Sample    int     `json:"sample"`
+	Running   bool    `json:"running"`
+	WPM       float64 `json:"wpm"`
+	CPM       float64 `json:"cpm"`
+	Accuracy  float64 `json:"accuracy"`
+	Progress  float64 `json:"progress"`
+	ElapsedMs int64   `json:"elapsed_ms"`
+	Typos     int     `json:"typos"`
+	Ghost     float64 `json:"ghost_progress,omitempty"`
+}
+
+// servedSample is the index of the sample in state, for -serve.
+var servedSample int
+
+// stopServing shuts down the -serve server, if any.
+var stopServing = func() {}
+
+func currentStats() liveStats {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if state.Session == nil {
+		return liveStats{}
+	}
+	stats := liveStats{
+		Sample:    servedSample,
+		Running:   state.Started() && !state.Done(),
+		WPM:       state.WPM(),
+		CPM:       state.CPM(),
+		Accuracy:  runningAccuracy(),
+		Progress:  samplePercent(state.TypedIndex),
+		ElapsedMs: state.Elapsed().Milliseconds(),
+		Typos:     len(state.Mistakes),
+	}
+	if hasPb {
+		stats.Ghost = samplePercent(state.ghostIndex)
+	}
+	return stats
+}
+
+// startServer serves currentStats as JSON at addr, for overlays (a browser
+// source polling it, say) to show the run live. addr is a TCP address like
+// localhost:8080, or unix:path for a unix socket. Nothing is written to the
+// terminal, errors included. stopServing shuts the server down.
+func startServer(addr string) error {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+		// A socket left behind by a crash would make the listen fail.
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return fmt.Errorf("serving live stats: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(currentStats())
+	})
+	server := &http.Server{Handler: mux, ErrorLog: log.New(io.Discard, "", 0)}
+	go server.Serve(listener)
+
+	stopServing = func() {
+		server.Close()
+		if network == "unix" {
+			os.Remove(addr)
+		}
+	}
+	return nil
+}
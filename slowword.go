@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"ttt/typing"
+)
+
+// slowWordReps is how many times the slowest word drill has the word typed.
+const slowWordReps = 5
+
+// slowestWord finds the word of sample typed at the slowest pace, per
+// character, going by charTimes. The time of a word's first character is
+// the gap since the space before it, so the hesitation before a hard word
+// counts against it. Single letters are left out. ok is false if there's no
+// word of two or more characters.
+func slowestWord(sample []rune, charTimes []int) (start, end int, ok bool) {
+	worst := -1.0
+	for i := 0; i < len(sample) && i < len(charTimes); {
+		if isSpace(sample[i]) {
+			i++
+			continue
+		}
+		j := min(i+wordLength(sample, i), len(charTimes))
+		ms := 0
+		for _, t := range charTimes[i:j] {
+			ms += t
+		}
+		if pace := float64(ms) / float64(j-i); j-i > 1 && pace > worst {
+			worst, start, end = pace, i, j
+		}
+		i = j
+	}
+	return start, end, worst >= 0
+}
+
+// drillSlowestWord is the results screen action that has the slowest word of
+// the run typed slowWordReps times on its own. Each repetition is timed
+// between its first and last keystroke, and its speed (counting -worddiv
+// characters a word, like every per-character speed) is compared with the
+// word's in the run: the improvement is the best clean repetition's.
+func drillSlowestWord(inputBuf *[]byte) error {
+	start, end, ok := slowestWord(state.Sample, state.CharTimes)
+	if !ok {
+		fmt.Print("\n\r\033[90m There's no word of two or more characters to drill. Press a key\033[0m")
+		_, err := readKey(inputBuf)
+		return err
+	}
+	word := string(state.Sample[start:end])
+	ms := 0
+	for _, t := range state.CharTimes[start:end] {
+		ms += t
+	}
+	// The run's time covers the gap into the first character, a repetition
+	// starts on it: both are timed over as many keystroke intervals.
+	runSpeed := charsWPM(end-start, ms)
+
+	var speeds []float64
+	for len(speeds) < slowWordReps {
+		s := typing.NewSession(word, nil)
+		for !s.Done() {
+			renderSlowWord(word, runSpeed, speeds, s)
+			r, err := readRune(inputBuf)
+			if err != nil {
+				return err
+			}
+			switch {
+			case r == 3:
+				handleCtrlC()
+			case r < 32 && r != typing.KeyEsc && r != typing.KeyCtrlBackspace && r != typing.KeyCtrlShiftBackspace:
+				continue
+			}
+			s.Feed(r)
+		}
+		speed := 0.0
+		if len(s.Typos) == 0 && s.Elapsed() > 0 {
+			speed = charsWPM(end-start-1, int(s.Elapsed().Milliseconds()))
+		}
+		speeds = append(speeds, speed)
+	}
+
+	renderSlowWord(word, runSpeed, speeds, nil)
+	best := slices.Max(speeds)
+	if best == 0 {
+		fmt.Print("\n\r\n\r No clean repetition, typos leave a repetition unscored.")
+	} else {
+		fmt.Printf("\n\r\n\r Best %.0f wpm, %+.0f wpm on the run.", best, best-runSpeed)
+	}
+	fmt.Print("\033[90m Press a key\033[0m")
+	_, err := readKey(inputBuf)
+	return err
+}
+
+// renderSlowWord draws the slowest word drill: the word with its speed in
+// the run, the repetitions so far and, unless s is nil, the one being typed.
+func renderSlowWord(word string, runSpeed float64, speeds []float64, s *typing.Session) {
+	frame := new(bytes.Buffer)
+	fmt.Fprint(frame, "\033[2J") //clean screen
+	fmt.Fprintf(frame, "\033[H") //return home
+	fmt.Fprintf(frame, " Slowest word: \033[97m%s\033[0m at %.0f wpm in the run, type it %d times\n\r\n\r", word, runSpeed, slowWordReps)
+	for i, speed := range speeds {
+		if speed == 0 {
+			fmt.Fprintf(frame, "\033[90m %d. typos, not scored\033[0m\n\r", i+1)
+		} else {
+			fmt.Fprintf(frame, " %d. %.0f wpm\n\r", i+1, speed)
+		}
+	}
+	if s != nil {
+		var line strings.Builder
+		for i, r := range s.Sample {
+			switch {
+			case i >= s.TypedIndex:
+				fmt.Fprintf(&line, "\033[90m%c", r)
+			case slices.Contains(s.Typos, i):
+				fmt.Fprintf(&line, "\033[4;91m%c\033[0m", typoGlyph(r))
+			default:
+				fmt.Fprintf(&line, "%s%c\033[0m", typedColor(), r)
+			}
+		}
+		fmt.Fprintf(frame, " %d. %s\033[0m\033[%dG", len(speeds)+1, line.String(), len(fmt.Sprint(len(speeds)+1))+4+cellsBefore(s.Sample, s.TypedIndex))
+	}
+	out.Write(frame.Bytes())
+}
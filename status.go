@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// wordsLeft[i] is the number of words in the sample from index i onwards, so
+// -remaining and -wordpos don't recount the sample on every keystroke.
+var wordsLeft []int
+
+func suffixWordCounts(sample []rune) []int {
+	counts := make([]int, len(sample)+1)
+	for i := len(sample) - 1; i >= 0; i-- {
+		counts[i] = counts[i+1]
+		isSpace := sample[i] == ' ' || sample[i] == '\n' || sample[i] == '\t'
+		startsWord := i == 0 || sample[i-1] == ' ' || sample[i-1] == '\n' || sample[i-1] == '\t'
+		if !isSpace && startsWord {
+			counts[i]++
+		}
+	}
+	return counts
+}
+
+// statusLine builds the status row from every enabled indicator.
+func statusLine() string {
+	var parts []string
+	if *metronome > 0 {
+		if metronomeBeat {
+			parts = append(parts, "\033[97m●")
+		} else {
+			parts = append(parts, "\033[90m○")
+		}
+	}
+	if *teach && state.TypedIndex < len(state.Sample) {
+		parts = append(parts, fmt.Sprintf("\033[90mnext key: \033[97m%s", keyName(state.Sample[state.TypedIndex])))
+	}
+	if *goalWPM > 0 {
+		parts = append(parts, fmt.Sprintf("\033[90mattempt %d, best %.1f wpm, target %.0f wpm", queue.attempts+1, queue.best, *goalWPM))
+	}
+	if *eta {
+		parts = append(parts, "\033[90m"+etaText())
+	}
+	if *race {
+		parts = append(parts, raceText())
+	}
+	if *remaining {
+		parts = append(parts, fmt.Sprintf("\033[90m%d/%d chars, %d words left", state.CharCount(state.TypedIndex), state.CharCount(len(state.Sample)), wordsLeft[state.TypedIndex]))
+	}
+	if *wordPos {
+		parts = append(parts, "\033[90m"+wordPosText())
+	}
+	if state.Paused() {
+		parts = append([]string{"\033[93mpaused, Ctrl-P to resume"}, parts...)
+	}
+	if accuracyWarning {
+		// The red background fills the rest of the row too.
+		parts = append([]string{fmt.Sprintf("\033[97maccuracy %.1f%%, slow down", runningAccuracy())}, parts...)
+		return "\033[41m " + strings.Join(parts, "\033[90m  |  ") + "\033[K"
+	}
+	return " " + strings.Join(parts, "\033[90m  |  ")
+}
+
+// wordPosText shows which word of the sample is being typed. Past the end
+// of a word, on the spaces after it, it's still that word until the next one
+// starts.
+func wordPosText() string {
+	total := wordsLeft[0]
+	started := total - wordsLeft[min(state.TypedIndex+1, len(state.Sample))]
+	return fmt.Sprintf("word %d of %d", min(max(started, 1), total), total)
+}
+
+// samplePercent is how far through the sample index i is, in percent. An
+// empty sample has nothing to go through, it's done from the start.
+func samplePercent(i int) float64 {
+	if len(state.Sample) == 0 {
+		return 100
+	}
+	return 100 * float64(i) / float64(len(state.Sample))
+}
+
+// raceText shows how far through the sample the typing and the ghost are,
+// and whether the pace so far would beat the ghost.
+func raceText() string {
+	you := int(samplePercent(state.TypedIndex))
+	if !hasPb {
+		return fmt.Sprintf("\033[97myou %d%%  \033[95mghost --", you)
+	}
+	text := fmt.Sprintf("\033[97myou %d%%  \033[95mghost %d%%", you, int(samplePercent(state.ghostIndex)))
+	if lead, ok := ghostLead(); ok && lead >= 0 {
+		text += fmt.Sprintf("  \033[92mon pace +%.1fs", lead.Seconds())
+	} else if ok {
+		text += fmt.Sprintf("  \033[91m%.1fs behind", lead.Seconds())
+	}
+	return text
+}
+
+// ghostLead is how far ahead of the ghost the run would finish if the rest
+// of the sample went at the average pace so far, negative when behind. Like
+// -eta, it needs etaMinChars typed to go by.
+func ghostLead() (time.Duration, bool) {
+	typed := state.TypedIndex
+	if typed < etaMinChars || !state.Started() {
+		return 0, false
+	}
+	finish := state.Elapsed() * time.Duration(len(state.Sample)) / time.Duration(typed)
+	return ghostTotal - finish, true
+}
+
+// etaMinChars is how many characters have to be typed before -eta trusts the
+// pace enough to show an estimate.
+const etaMinChars = 10
+
+// etaText estimates the time left to finish the sample at the average pace
+// so far.
+func etaText() string {
+	typed := state.TypedIndex
+	if typed < etaMinChars || !state.Started() {
+		return "eta --"
+	}
+	pace := state.Elapsed() / time.Duration(typed)
+	return "eta " + formatElapsed(pace*time.Duration(len(state.Sample)-typed))
+}
+
+// statusRow is the row the status is drawn on: the last one, or with -inline
+// the one under the sample.
+func statusRow() int {
+	if *inline {
+		return topRows() + 2
+	}
+	return terminalHeight
+}
+
+func drawStatus(frame *bytes.Buffer, text string) {
+	fmt.Fprintf(frame, "\0337")                          //save typing position
+	fmt.Fprintf(frame, "\033[%d;1H\033[2K", statusRow()) //clear status row
+	fmt.Fprintf(frame, "%s\033[0m", text)
+	fmt.Fprintf(frame, "\0338") //back to saved typing position
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ttt/typing"
+)
+
+// An empty sample is shown as done rather than dividing by its length, in
+// the -race status and in what -serve answers.
+func TestEmptySampleProgress(t *testing.T) {
+	saveGlobals(t)
+	state = State{Session: typing.NewSession("", nil)}
+	for _, pb := range []bool{false, true} {
+		hasPb = pb
+		if text := raceText(); !strings.Contains(text, "you 100%") {
+			t.Errorf("race status %q with a personal best %v, want you at 100%%", text, pb)
+		}
+		if _, err := json.Marshal(currentStats()); err != nil {
+			t.Errorf("live stats with a personal best %v: %v", pb, err)
+		}
+	}
+
+	state = State{Session: typing.NewSession("abcd", nil)}
+	state.Feed('a')
+	state.ghostIndex = 3
+	if text := raceText(); !strings.Contains(text, "you 25%") || !strings.Contains(text, "ghost 75%") {
+		t.Errorf("race status %q, want you at 25%% and the ghost at 75%%", text)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// binaryMagic starts a samples file saved with -format binary, which is how
+// it's told apart from JSON when loading. The last byte is the version of
+// the encoding after it.
+const binaryMagic = "TTTS\x01"
+
+// binarySamples is set when the samples file is in the binary format, which
+// it's saved back in.
+var binarySamples bool
+
+func isBinarySamples(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(binaryMagic))
+}
+
+// parseBinarySamples decodes a samples file saved with -format binary: the
+// samples gob encoded after binaryMagic. It's much faster to load than JSON
+// once there are many long samples, their char times above all.
+func parseBinarySamples(data []byte) ([]SavedSample, error) {
+	var samples []SavedSample
+	if err := gob.NewDecoder(bytes.NewReader(data[len(binaryMagic):])).Decode(&samples); err != nil {
+		return nil, fmt.Errorf("decoding binary samples: %w", err)
+	}
+	return samples, nil
+}
+
+func encodeBinarySamples(w io.Writer, samples []SavedSample) error {
+	if _, err := io.WriteString(w, binaryMagic); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(samples)
+}
+
+// convertSamples saves the samples file in the format given with -format, if
+// it isn't in it already. From then on it's kept in that format.
+func convertSamples(format string) {
+	if binary := format == "binary"; binary != binarySamples {
+		binarySamples = binary
+		saveSamples(samplesPath)
+	}
+}
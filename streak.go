@@ -0,0 +1,21 @@
+package main
+
+import "time"
+
+const dateLayout = "2006-01-02"
+
+// updateStreak returns the practice streak after practicing on today, given
+// the date a sample was last practiced and its streak then. Practicing again
+// the same day keeps the streak, the day after extends it and any later
+// breaks it. Samples never practiced, or saved before streaks were tracked,
+// start at one.
+func updateStreak(last string, streak int, today time.Time) (string, int) {
+	date := today.Format(dateLayout)
+	switch last {
+	case date:
+		return date, max(streak, 1)
+	case today.AddDate(0, 0, -1).Format(dateLayout):
+		return date, streak + 1
+	}
+	return date, 1
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// teachIndex is the sample position currently highlighted as the next key.
+var teachIndex = -1
+
+const (
+	shiftedKeys   = "~!@#$%^&*()_+{}|:\"<>?"
+	unshiftedKeys = "`1234567890-=[]\\;',./"
+)
+
+// keyName describes the keystroke that produces r on a US QWERTY layout,
+// e.g. "Shift+;" for ':'.
+func keyName(r rune) string {
+	switch r {
+	case ' ':
+		return "Space"
+	case '\n':
+		return "Enter"
+	case '\t':
+		return "Tab"
+	}
+	if unicode.IsUpper(r) {
+		return "Shift+" + string(unicode.ToLower(r))
+	}
+	if i := strings.IndexRune(shiftedKeys, r); i >= 0 {
+		return "Shift+" + string(unshiftedKeys[i])
+	}
+	return string(r)
+}
+
+// drawSampleChar repaints the sample character at index i with the given
+// color, leaving the typing cursor where it was.
+func drawSampleChar(frame *bytes.Buffer, i int, color string) {
+	ch := state.Sample[i]
+	if ch == '\n' || ch == '\t' {
+		ch = ' '
+	}
+	drawGlyph(frame, i, color, ch)
+}
+
+// drawGlyph writes ch in the cell of the sample character at index i.
+func drawGlyph(frame *bytes.Buffer, i int, color string, ch rune) {
+	row, col := cellPosition(state.Sample, i)
+	fmt.Fprintf(frame, "\0337")                                           //save typing position
+	fmt.Fprintf(frame, "\033[%d;%dH", topRows()+row+1, gutterWidth+col+1) //position in sample index
+	fmt.Fprintf(frame, "%s%c\033[0m", color, ch)                          //write char
+	fmt.Fprintf(frame, "\0338")                                           //back to saved typing position
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// trailCell is a recently typed character still fading out with -trail.
+type trailCell struct {
+	index, row, col int
+	typedAt         time.Time
+
+	// ghostAhead is whether the ghost had already drawn this character when
+	// it was typed. If not, once the ghost passes it the ghost's color wins
+	// and the cell is left alone.
+	ghostAhead bool
+}
+
+var trail []trailCell
+
+// trailColors are the 256-color shades a typed character goes through, one
+// per trailStep, before settling on the normal typed color.
+var trailColors = []int{51, 87, 123, 159}
+
+const trailStep = 80 * time.Millisecond
+
+// addTrail starts the trail of the character at index, drawn at row and col
+// of the typing area, and returns the color to draw it with now. Entries for
+// that index or later were backspaced over and are dropped.
+func addTrail(index, row, col int) int {
+	trail = slices.DeleteFunc(trail, func(c trailCell) bool { return c.index >= index })
+	trail = append(trail, trailCell{
+		index:      index,
+		row:        row,
+		col:        col,
+		typedAt:    time.Now(),
+		ghostAhead: state.ghostIndex > index,
+	})
+	return trailColors[0]
+}
+
+// drawTrail repaints every character of the trail in its current shade and
+// forgets the ones that are done fading.
+func drawTrail(frame *bytes.Buffer) {
+	now := time.Now()
+	kept := trail[:0]
+	for _, c := range trail {
+		if c.index >= state.TypedIndex || slices.Contains(state.Typos, c.index) {
+			continue
+		}
+		if !c.ghostAhead && state.ghostIndex > c.index {
+			continue
+		}
+
+		color := typedColor()
+		if stage := int(now.Sub(c.typedAt) / trailStep); stage < len(trailColors) {
+			color = fmt.Sprintf("\033[38;5;%dm", trailColors[stage])
+			kept = append(kept, c)
+		}
+		fmt.Fprintf(frame, "\0337")                                               //save typing position
+		fmt.Fprintf(frame, "\033[%d;%dH", topRows()+c.row+1, gutterWidth+c.col+1) //position in trail cell
+		fmt.Fprintf(frame, "%s%c\033[0m", color, state.Sample[c.index])
+		fmt.Fprintf(frame, "\0338") //back to saved typing position
+	}
+	trail = kept
+}
+
+// startTrail fades the trail until the returned function is called.
+func startTrail() func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(trailStep)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+
+			stateMu.Lock()
+			if len(trail) > 0 {
+				render(0, "trail")
+			}
+			stateMu.Unlock()
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		trail = nil
+	}
+}
@@ -0,0 +1,81 @@
+package typing
+
+import "unicode"
+
+// ClusterLen returns how many runes make up the user-perceived character
+// (grapheme cluster) that starts at s[i]: a base character with its
+// combining marks, variation selectors and skin tone modifiers, emoji joined
+// with zero width joiners, flag pairs, Indic conjuncts (consonants joined by a
+// virama) and CR LF.
+//
+// It's a simplified take on the Unicode segmentation rules: Hangul syllables
+// typed as separate jamo, prepended marks and a few rarer cases count as more
+// than one character.
+func ClusterLen(s []rune, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	j := i + 1
+	switch {
+	case s[i] == '\r':
+		if j < len(s) && s[j] == '\n' {
+			j++
+		}
+		return j - i
+	case isRegionalIndicator(s[i]):
+		if j < len(s) && isRegionalIndicator(s[j]) {
+			j++
+		}
+		return j - i
+	case unicode.IsControl(s[i]):
+		return 1
+	}
+
+	for j < len(s) {
+		switch prev := s[j-1]; {
+		case isExtend(s[j]):
+			j++
+		case prev == zeroWidthJoiner && !unicode.IsControl(s[j]):
+			j++
+		case isVirama(prev) && unicode.IsLetter(s[j]):
+			j++
+		default:
+			return j - i
+		}
+	}
+	return j - i
+}
+
+// CountGraphemes is the number of user-perceived characters in s.
+func CountGraphemes(s []rune) int {
+	n := 0
+	for i := 0; i < len(s); i += ClusterLen(s, i) {
+		n++
+	}
+	return n
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner ||
+		r >= 0xfe00 && r <= 0xfe0f || // variation selectors
+		r >= 0x1f3fb && r <= 0x1f3ff || // skin tone modifiers
+		r >= 0xe0020 && r <= 0xe007f || // tags, in subdivision flags
+		r >= 0xe0100 && r <= 0xe01ef // variation selectors supplement
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isVirama reports whether r is the virama of one of the main Indic scripts,
+// which joins the consonants around it into a single character.
+func isVirama(r rune) bool {
+	switch r {
+	case '\u094d', '\u09cd', '\u0a4d', '\u0acd', '\u0b4d', '\u0bcd', '\u0c4d', '\u0ccd', '\u0d4d':
+		return true
+	}
+	return false
+}
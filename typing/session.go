@@ -0,0 +1,357 @@
+// Package typing is the typing test engine: it matches keystrokes against a
+// sample, keeps track of typos and per-character timing, and computes the
+// results. It does no I/O, so any front end can drive it by feeding it the
+// runes it reads and drawing the state however it likes.
+package typing
+
+import (
+	"slices"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Editing keys understood by Session.Feed, as sent by terminals in raw mode.
+const (
+	KeyBackspace          = 127
+	KeyCtrlBackspace      = 23
+	KeyCtrlShiftBackspace = 8
+	KeyEsc                = 27
+)
+
+// MinTimedChars is the fewest characters a run is timed over. The clock
+// starts on the first keystroke, so a shorter run gives its speed from one
+// or two intervals between keys, which says little and can be huge.
+const MinTimedChars = 5
+
+type Session struct {
+	// Sample is the text to type, in NFC form.
+	Sample []rune
+	// TypedIndex is the position of the next character to type.
+	TypedIndex int
+	// Typos holds the typed positions that are still wrong.
+	Typos []int
+	// Mistakes holds the position of every typo made, even if later fixed,
+	// and MistakeRunes what was typed instead each time.
+	Mistakes     []int
+	MistakeRunes []rune
+	// CharTimes is the time in milliseconds taken to type each character.
+	// Characters typed while an earlier typo was pending keep their
+	// previous value.
+	CharTimes []int
+
+	// Now is the clock used for timing, time.Now unless replaced.
+	Now func() time.Time
+
+	// Graphemes makes CPM and CharCount count user-perceived characters
+	// (see ClusterLen) instead of runes.
+	Graphemes bool
+
+	// WordLen, if positive, makes WPM count every WordLen correctly typed
+	// characters as a word (the usual "net wpm" of typing tests) instead of
+	// the words actually typed.
+	WordLen int
+
+	start    time.Time
+	end      time.Time
+	charTime time.Time
+	resumed  time.Duration
+	pausedAt time.Time
+}
+
+// NewSession starts a session on text. charTimes, if it matches the sample's
+// length, provides the initial CharTimes (usually the personal best's).
+func NewSession(text string, charTimes []int) *Session {
+	s := &Session{
+		Sample:   []rune(norm.NFC.String(text)),
+		Typos:    make([]int, 0),
+		Mistakes: make([]int, 0),
+		Now:      time.Now,
+	}
+	s.CharTimes = make([]int, len(s.Sample))
+	if len(charTimes) == len(s.Sample) {
+		copy(s.CharTimes, charTimes)
+	}
+	return s
+}
+
+// Feed handles a keystroke: the expected character (Enter for a newline)
+// advances, the editing keys move back (Esc with CancelWord), and anything
+// else is a typo.
+func (s *Session) Feed(r rune) {
+	if s.Done() {
+		return
+	}
+	now := s.Now()
+	if s.start.IsZero() {
+		s.start = now.Add(-s.resumed)
+		s.charTime = now
+	}
+
+	expected := s.Sample[s.TypedIndex]
+	switch {
+	case r == expected, r == '\r' && expected == '\n':
+		s.correct(now)
+	case r == KeyBackspace:
+		s.Backspace()
+	case r == KeyCtrlBackspace:
+		s.DeleteWord()
+	case r == KeyCtrlShiftBackspace:
+		s.DeleteAll()
+	case r == KeyEsc:
+		s.CancelWord()
+	default:
+		s.typo(r)
+	}
+
+	if s.Done() {
+		s.end = now
+	}
+}
+
+func (s *Session) correct(now time.Time) {
+	if idx := slices.Index(s.Typos, s.TypedIndex); idx >= 0 {
+		s.Typos = slices.Delete(s.Typos, idx, idx+1)
+	}
+	if s.TypedIndex == 0 {
+		s.charTime = now
+	}
+	if len(s.Typos) == 0 {
+		s.CharTimes[s.TypedIndex] = int(now.Sub(s.charTime).Milliseconds())
+		s.charTime = now
+	}
+	s.TypedIndex++
+}
+
+func (s *Session) typo(r rune) {
+	if !slices.Contains(s.Typos, s.TypedIndex) {
+		s.Typos = append(s.Typos, s.TypedIndex)
+	}
+	s.Mistakes = append(s.Mistakes, s.TypedIndex)
+	s.MistakeRunes = append(s.MistakeRunes, r)
+	s.TypedIndex++
+}
+
+// Skip moves past the character at the typing position as if it had been
+// typed instantly, with no time of its own, for characters the front end
+// doesn't ask for. Skipping the last one finishes the run, like typing it.
+func (s *Session) Skip() {
+	if s.Done() {
+		return
+	}
+	s.CharTimes[s.TypedIndex] = 0
+	s.TypedIndex++
+	if s.Done() {
+		s.end = s.Now()
+	}
+}
+
+// Resume makes the clock of a session restored partway through (by setting
+// TypedIndex and the rest) carry on from elapsed at the next keystroke.
+func (s *Session) Resume(elapsed time.Duration) {
+	s.resumed = elapsed
+}
+
+// Insert adds text to the sample at position at, which can't be before
+// TypedIndex since the typed part stays as it is. The new characters start
+// with no time recorded.
+func (s *Session) Insert(at int, text []rune) {
+	if at < s.TypedIndex || at > len(s.Sample) {
+		return
+	}
+	s.Sample = slices.Insert(s.Sample, at, text...)
+	s.CharTimes = slices.Insert(s.CharTimes, at, make([]int, len(text))...)
+}
+
+// Backspace moves back one character.
+func (s *Session) Backspace() {
+	if s.TypedIndex > 0 {
+		s.TypedIndex--
+	}
+}
+
+// DeleteWord moves back to the start of the current word.
+func (s *Session) DeleteWord() {
+	if s.TypedIndex > 0 {
+		for ok := true; ok; ok = (s.TypedIndex > 0 && s.Sample[s.TypedIndex-1] != ' ') {
+			s.TypedIndex--
+		}
+	}
+}
+
+// CancelWord takes back the word being typed: it moves back to the start of
+// the run of non-whitespace around the typing position and drops the typos
+// pending from there on, which are typed again. A typo on the whitespace
+// right after a word (a space typed too early or missed) belongs to it.
+// The typos still count as Mistakes.
+func (s *Session) CancelWord() {
+	start := s.TypedIndex
+	for start > 0 && isWhitespace(s.Sample[start-1]) && slices.Contains(s.Typos, start-1) {
+		start--
+	}
+	for start > 0 && !isWhitespace(s.Sample[start-1]) {
+		start--
+	}
+	s.Typos = slices.DeleteFunc(s.Typos, func(i int) bool { return i >= start })
+	s.TypedIndex = start
+}
+
+// DeleteAll moves back to the start of the sample.
+func (s *Session) DeleteAll() {
+	s.TypedIndex = 0
+}
+
+// Done reports whether the whole sample has been typed.
+func (s *Session) Done() bool {
+	return s.TypedIndex >= len(s.Sample)
+}
+
+// Started reports whether any keystroke has been fed yet.
+func (s *Session) Started() bool {
+	return !s.start.IsZero()
+}
+
+// Pause stops the clock of a started run until Unpause. The time spent
+// paused counts neither towards Elapsed nor towards the next character.
+func (s *Session) Pause() {
+	if s.Started() && !s.Done() && !s.Paused() {
+		s.pausedAt = s.Now()
+	}
+}
+
+// Unpause starts the clock again where Pause stopped it.
+func (s *Session) Unpause() {
+	if !s.Paused() {
+		return
+	}
+	paused := s.Now().Sub(s.pausedAt)
+	s.start = s.start.Add(paused)
+	s.charTime = s.charTime.Add(paused)
+	s.pausedAt = time.Time{}
+}
+
+// Paused reports whether the clock is stopped by Pause.
+func (s *Session) Paused() bool {
+	return !s.pausedAt.IsZero()
+}
+
+// Elapsed is the time from the first keystroke to the last one of a finished
+// session, or to now while it's still running, leaving out the time paused.
+func (s *Session) Elapsed() time.Duration {
+	if !s.Started() {
+		return 0
+	}
+	if s.Done() && !s.end.IsZero() {
+		return s.end.Sub(s.start)
+	}
+	if s.Paused() {
+		return s.pausedAt.Sub(s.start)
+	}
+	return s.Now().Sub(s.start)
+}
+
+// Accuracy is the percentage of keystrokes that were correct, counting every
+// typo made even if it was later fixed.
+func (s *Session) Accuracy() float64 {
+	if len(s.Sample) == 0 {
+		return 100
+	}
+	typed := len(s.Sample) + len(s.Mistakes)
+	return 100 * float64(len(s.Sample)) / float64(typed)
+}
+
+// Timed reports whether enough of the sample has been typed, over some time,
+// for the run to have a speed: at least MinTimedChars characters. A
+// one-character sample never does, its first keystroke is also the last.
+func (s *Session) Timed() bool {
+	return len(s.typedPart()) >= MinTimedChars && s.Elapsed() > 0
+}
+
+// WPM is the words typed per minute of Elapsed: those of the whole sample
+// once it's done, and only those up to TypedIndex for a run that stopped
+// partway. With WordLen set it's CPM divided by WordLen instead. It's zero
+// rather than huge or infinite unless the run is Timed.
+func (s *Session) WPM() float64 {
+	if !s.Timed() {
+		return 0
+	}
+	if s.WordLen > 0 {
+		return s.CPM() / float64(s.WordLen)
+	}
+	return float64(CountWords(s.typedPart())) / s.Elapsed().Minutes()
+}
+
+// CPM is the correctly typed characters per minute of Elapsed, zero like WPM
+// unless the run is Timed.
+func (s *Session) CPM() float64 {
+	if !s.Timed() {
+		return 0
+	}
+	typed := len(s.typedPart())
+	typos := 0
+	for _, i := range s.Typos {
+		if i < typed {
+			typos++
+		}
+	}
+	return float64(s.CharCount(typed)-typos) / s.Elapsed().Minutes()
+}
+
+// typedPart is the part of the sample typed so far.
+func (s *Session) typedPart() []rune {
+	return s.Sample[:min(s.TypedIndex, len(s.Sample))]
+}
+
+// CharCount is the number of characters in the first n runes of the sample.
+func (s *Session) CharCount(n int) int {
+	n = min(n, len(s.Sample))
+	if !s.Graphemes {
+		return n
+	}
+	return CountGraphemes(s.Sample[:n])
+}
+
+type Result struct {
+	Elapsed  time.Duration
+	WPM      float64
+	CPM      float64
+	Accuracy float64
+	// Typos is the number of typos left unfixed, Mistakes the number made.
+	Typos    int
+	Mistakes int
+}
+
+func (s *Session) Result() Result {
+	return Result{
+		Elapsed:  s.Elapsed(),
+		WPM:      s.WPM(),
+		CPM:      s.CPM(),
+		Accuracy: s.Accuracy(),
+		Typos:    len(s.Typos),
+		Mistakes: len(s.Mistakes),
+	}
+}
+
+func isWhitespace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t'
+}
+
+func CountWords(sample []rune) int {
+	inWord := false
+	wordCount := 0
+
+	for _, r := range sample {
+		if r == ' ' || r == '\n' || r == '\t' {
+			if inWord {
+				inWord = false
+			}
+		} else {
+			if !inWord {
+				inWord = true
+				wordCount++
+			}
+		}
+	}
+
+	return wordCount
+}
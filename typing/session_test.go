@@ -0,0 +1,99 @@
+package typing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// A run stopped halfway through the sample only counts the words and
+// characters typed for its speed.
+func TestPartialRunSpeed(t *testing.T) {
+	s := NewSession("aaaa bbbb cccc dddd", nil)
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time { return now }
+	for _, r := range "aaaa bbbb " {
+		s.Feed(r)
+		now = now.Add(100 * time.Millisecond)
+	}
+	now = now.Add(-100 * time.Millisecond)
+
+	switch {
+	case s.Done():
+		t.Fatalf("run finished at index %d", s.TypedIndex)
+	case s.Elapsed() != 900*time.Millisecond:
+		t.Fatalf("elapsed %v, want 900ms", s.Elapsed())
+	}
+	if math.Abs(s.WPM()-2/0.015) > 0.01 {
+		t.Errorf("wpm %.2f, want 133.33 for 2 of the 4 words", s.WPM())
+	}
+	if math.Abs(s.CPM()-10/0.015) > 0.01 {
+		t.Errorf("cpm %.2f, want 666.67 for 10 characters", s.CPM())
+	}
+	s.WordLen = 4
+	if math.Abs(s.WPM()-2.5/0.015) > 0.01 {
+		t.Errorf("wpm %.2f with WordLen 4, want 166.67 for 10 characters", s.WPM())
+	}
+}
+
+// A run under MinTimedChars characters has no speed rather than one worked
+// out from one or two intervals between keys, or none at all.
+func TestShortRunSpeed(t *testing.T) {
+	for _, text := range []string{"a", "ab", "abcd"} {
+		s := NewSession(text, nil)
+		now := time.Unix(0, 0)
+		s.Now = func() time.Time { return now }
+		for _, r := range text {
+			s.Feed(r)
+			now = now.Add(time.Millisecond)
+		}
+		if !s.Done() || s.Timed() || s.WPM() != 0 || s.CPM() != 0 {
+			t.Errorf("%q done %v, timed %v at %.0f wpm and %.0f cpm, want done and untimed at 0", text, s.Done(), s.Timed(), s.WPM(), s.CPM())
+		}
+	}
+}
+
+// Skipping the last character finishes the run then, and the time stops.
+func TestSkipFinishes(t *testing.T) {
+	s := NewSession("ab.", nil)
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time { return now }
+	s.Feed('a')
+	now = now.Add(200 * time.Millisecond)
+	s.Feed('b')
+	s.Skip()
+	now = now.Add(time.Minute)
+
+	if !s.Done() || s.Elapsed() != 200*time.Millisecond || s.CharTimes[2] != 0 {
+		t.Errorf("done %v after %v with char times %v, want done after 200ms with the skip at 0", s.Done(), s.Elapsed(), s.CharTimes)
+	}
+}
+
+// The time a run is paused counts neither towards its time nor towards the
+// character typed after it.
+func TestPause(t *testing.T) {
+	s := NewSession("abc", nil)
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time { return now }
+	s.Pause()
+	if s.Paused() {
+		t.Fatal("a run that hasn't started was paused")
+	}
+
+	s.Feed('a')
+	now = now.Add(100 * time.Millisecond)
+	s.Feed('b')
+	now = now.Add(50 * time.Millisecond)
+	s.Pause()
+	now = now.Add(time.Minute)
+	if !s.Paused() || s.Elapsed() != 150*time.Millisecond {
+		t.Errorf("paused %v at %v, want paused at 150ms", s.Paused(), s.Elapsed())
+	}
+	s.Unpause()
+	now = now.Add(50 * time.Millisecond)
+	s.Feed('c')
+
+	if s.Paused() || s.Elapsed() != 200*time.Millisecond || s.CharTimes[2] != 100 {
+		t.Errorf("done after %v with char times %v, want 200ms with 100ms for c", s.Elapsed(), s.CharTimes)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// version can be set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+func printVersion() {
+	fmt.Println("terminal typing test", version)
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	fmt.Println("go:", info.GoVersion)
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified":
+			fmt.Printf("%s: %s\n", setting.Key, setting.Value)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/term"
+
+	"ttt/typing"
+)
+
+// runZen is a free typing session: there's no sample to match, everything
+// typed counts, and Ctrl-D ends the session.
+func runZen() error {
+	var err error
+	oldState, err = setupTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(tty.Fd()), oldState)
+
+	var typed []rune
+	var start time.Time
+	var inputBuf []byte
+	zenShown = nil
+	fmt.Fprint(out, "\033[2J\033[H") //clean and home
+	renderZen(typed, start)
+	for {
+		r, err := readRune(&inputBuf)
+		if err != nil {
+			fmt.Print("\033[2J\033[H")
+			return nil
+		}
+
+		switch {
+		case r == 3:
+			handleCtrlC()
+		case r == 4: //ctrl-d
+			if !start.IsZero() {
+				displayZenResults(typed, time.Since(start))
+			}
+			return nil
+		case r == 127:
+			if len(typed) > 0 {
+				typed = typed[:len(typed)-1]
+			}
+		case r == 23: //ctrl-backspace
+			trimmed := strings.TrimRight(string(typed), " \n\t")
+			if i := strings.LastIndexAny(trimmed, " \n\t"); i >= 0 {
+				typed = []rune(trimmed[:i+1])
+			} else {
+				typed = typed[:0]
+			}
+		case r == 13 || r == 10:
+			typed = append(typed, '\n')
+		case r == '\t' || r >= 32:
+			typed = append(typed, r)
+		default:
+			continue
+		}
+
+		if start.IsZero() {
+			start = time.Now()
+		}
+		renderZen(typed, start)
+	}
+}
+
+// zenShown is the text on the screen, which renderZen only draws the change
+// from: what was typed after it, or a single character erased.
+var zenShown []rune
+
+// renderZen draws typed and the status row. Only a change it can't draw in
+// place (deleting a word, a line break or the last character of a row) has
+// the text drawn again from the top.
+func renderZen(typed []rune, start time.Time) {
+	frame := new(bytes.Buffer)
+	shown := len(zenShown)
+	switch {
+	case len(typed) >= shown && slices.Equal(typed[:shown], zenShown):
+		fmt.Fprintf(frame, "\033[97m%s\033[0m", zenText(typed[shown:]))
+	case len(typed) == shown-1 && slices.Equal(typed, zenShown[:len(typed)]) && zenErasable(typed, zenShown[len(typed)]):
+		fmt.Fprint(frame, "\b \b") //erase the last character
+	default:
+		fmt.Fprintf(frame, "\033[H\033[J\033[97m%s\033[0m", zenText(typed)) //home, clean and draw it all
+	}
+	zenShown = slices.Clone(typed)
+
+	status := " zen mode, Ctrl-D to finish"
+	if !start.IsZero() {
+		speed := "--"
+		if wpm, ok := zenWPM(typed, time.Since(start)); ok {
+			speed = fmt.Sprintf("%.0f", wpm)
+		}
+		status = fmt.Sprintf(" wpm: %s  words: %d  |%s", speed, typing.CountWords(typed), status)
+	}
+	drawStatus(frame, "\033[90m"+status)
+	out.Write(frame.Bytes())
+}
+
+// zenText is text as it's written to the terminal, where in raw mode a line
+// break needs a carriage return too.
+func zenText(text []rune) string {
+	return strings.ReplaceAll(string(text), "\n", "\r\n")
+}
+
+// zenErasable reports whether r, typed after text, can be erased in place by
+// moving back over it: it's one column wide and not in the last column of a
+// row, where the cursor doesn't move past it.
+func zenErasable(text []rune, r rune) bool {
+	if r == '\n' || r == '\t' || runeWidth(r) != 1 {
+		return false
+	}
+	line := 0
+	for i, c := range text {
+		if c == '\n' {
+			line = i + 1
+		}
+	}
+	col := 0
+	for _, c := range text[line:] {
+		if runeWidth(c) == 2 && col == terminalWidth-1 {
+			col = 0 // a wide character doesn't fit in the last column
+		}
+		if col += runeWidth(c); col >= terminalWidth {
+			col = 0
+		}
+	}
+	return col < terminalWidth-1
+}
+
+// zenWPM is the speed of typing text in elapsed. Like a run on a sample, ok
+// is false until there's enough of it for a speed to mean something.
+func zenWPM(text []rune, elapsed time.Duration) (wpm float64, ok bool) {
+	if len(text) < typing.MinTimedChars || elapsed <= 0 {
+		return 0, false
+	}
+	words := float64(typing.CountWords(text))
+	if n := wordLen(); n > 0 {
+		words = float64(len(text)) / float64(n)
+	}
+	return words / elapsed.Minutes(), true
+}
+
+func displayZenResults(typed []rune, elapsed time.Duration) {
+	fmt.Print("\033[2J") //clean screen
+	fmt.Printf("\033[H") //return home
+	words := typing.CountWords(typed)
+
+	if wpm, ok := zenWPM(typed, elapsed); ok {
+		fmt.Printf("\033[42m wpm: %.1f\033[0m\t", wpm)
+	} else {
+		fmt.Printf("\033[42m wpm: n/a, too short to time\033[0m\t")
+	}
+	fmt.Printf("\033[42m Time: %s\033[0m\t", formatElapsed(elapsed))
+	fmt.Printf("\033[42m Words: %d\033[0m\t", words)
+	fmt.Printf("\033[42m Characters: %d\033[0m\n\r", len(typed))
+}
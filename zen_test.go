@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZenWPM(t *testing.T) {
+	for _, test := range []struct {
+		text    string
+		elapsed time.Duration
+		wpm     float64
+		ok      bool
+	}{
+		{"", time.Second, 0, false},
+		{"hi", 100 * time.Millisecond, 0, false},
+		{"hello", 0, 0, false},
+		{"hello world", 6 * time.Second, 20, true},
+	} {
+		if wpm, ok := zenWPM([]rune(test.text), test.elapsed); wpm != test.wpm || ok != test.ok {
+			t.Errorf("zenWPM(%q, %v) = %.1f, %v, want %.1f, %v", test.text, test.elapsed, wpm, ok, test.wpm, test.ok)
+		}
+	}
+}
+
+// Zen mode draws what's typed without clearing the screen: new characters
+// are written after the old ones and a backspace erases in place, except
+// where that can't be done.
+func TestRenderZenIncremental(t *testing.T) {
+	saveGlobals(t)
+	frame := new(bytes.Buffer)
+	out = frame
+	terminalWidth, terminalHeight = 10, 24
+	zenShown = nil
+	start := time.Now().Add(-time.Minute)
+
+	const redraw = "\033[H\033[J"
+	for _, test := range []struct {
+		typed, want string
+		redraws     bool
+	}{
+		{"ab", "ab", false},
+		{"ab c", " c", false},
+		{"ab ", "\b \b", false},
+		{"ab cd\nef", "cd\r\nef", false},
+		{"ab cd\ne", "\b \b", false},
+		{"ab cd\n", "\b \b", false},
+		{"ab cd", "ab cd", true},
+		{"ab cd\n123456789", "\r\n123456789", false},
+		{"ab cd\n1234567890", "0", false},
+		{"ab cd\n123456789", "ab cd\r\n123456789", true},
+		{"ab ", "ab ", true},
+	} {
+		frame.Reset()
+		renderZen([]rune(test.typed), start)
+		if strings.Contains(frame.String(), "\033[2J") || strings.Contains(frame.String(), redraw) != test.redraws {
+			t.Errorf("typed %q drawn as %q, want redrawn from the top %v", test.typed, frame, test.redraws)
+		}
+		if !strings.Contains(frame.String(), test.want) {
+			t.Errorf("typed %q drawn as %q, want %q in it", test.typed, frame, test.want)
+		}
+	}
+}